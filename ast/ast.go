@@ -10,6 +10,8 @@ type NodeType string
 
 type Node interface {
 	String() string
+	Pos() token.Pos // position of the first character of the node
+	End() token.Pos // position of the first character after the node
 }
 
 type Statement interface {
@@ -27,6 +29,18 @@ type Program struct {
 	Statements []Statement
 }
 
+func (p *Program) Pos() token.Pos {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Pos{}
+}
+func (p *Program) End() token.Pos {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Pos{}
+}
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -47,9 +61,17 @@ type ExpressionStatement struct {
 	Type       string
 	Token      token.Token // the first token of the expression
 	Expression Expression
+	EndToken   token.Token // the terminating ';'
 }
 
 func (es *ExpressionStatement) statementNode() {}
+func (es *ExpressionStatement) Pos() token.Pos { return es.Token.Span.Start }
+func (es *ExpressionStatement) End() token.Pos {
+	if es.EndToken.Span.End.IsValid() || es.Expression == nil {
+		return es.EndToken.Span.End
+	}
+	return es.Expression.End()
+}
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -57,21 +79,25 @@ func (es *ExpressionStatement) String() string {
 
 	return ""
 }
-func NewExpressionStatement(t token.Token, e Expression) *ExpressionStatement {
+func NewExpressionStatement(t token.Token, e Expression, end token.Token) *ExpressionStatement {
 	return &ExpressionStatement{
 		Type:       "ExpressionStatement",
 		Token:      t,
 		Expression: e,
+		EndToken:   end,
 	}
 }
 
 type BlockStatement struct {
-	Type  string
-	Token token.Token // the first token of the expression
-	Body  []Statement
+	Type     string
+	Token    token.Token // the '{' token
+	Body     []Statement
+	EndToken token.Token // the '}' token
 }
 
 func (bs *BlockStatement) statementNode() {}
+func (bs *BlockStatement) Pos() token.Pos { return bs.Token.Span.Start }
+func (bs *BlockStatement) End() token.Pos { return bs.EndToken.Span.End }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Body {
@@ -80,11 +106,464 @@ func (bs *BlockStatement) String() string {
 
 	return out.String()
 }
-func NewBlockStatement(t token.Token, stmts []Statement) *BlockStatement {
+func NewBlockStatement(t token.Token, stmts []Statement, end token.Token) *BlockStatement {
 	return &BlockStatement{
-		Type:  "BlockStatement",
+		Type:     "BlockStatement",
+		Token:    t,
+		Body:     stmts,
+		EndToken: end,
+	}
+}
+
+type VariableDeclaration struct {
+	Type         string
+	Token        token.Token // the 'let' token
+	Declarations []*VariableDeclarator
+	EndToken     token.Token // the terminating ';'
+}
+
+func (vd *VariableDeclaration) statementNode() {}
+func (vd *VariableDeclaration) Pos() token.Pos { return vd.Token.Span.Start }
+func (vd *VariableDeclaration) End() token.Pos {
+	if vd.EndToken.Span.End.IsValid() || len(vd.Declarations) == 0 {
+		return vd.EndToken.Span.End
+	}
+	return vd.Declarations[len(vd.Declarations)-1].End()
+}
+func (vd *VariableDeclaration) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("let ")
+	for i, d := range vd.Declarations {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(d.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+func NewVariableDeclaration(t token.Token, declarations []*VariableDeclarator, end token.Token) *VariableDeclaration {
+	return &VariableDeclaration{
+		Type:         "VariableDeclaration",
+		Token:        t,
+		Declarations: declarations,
+		EndToken:     end,
+	}
+}
+
+type VariableDeclarator struct {
+	Type string
+	Id   *Identifier
+	Init Expression // nil when the variable has no initializer
+}
+
+func (vd *VariableDeclarator) Pos() token.Pos { return vd.Id.Pos() }
+func (vd *VariableDeclarator) End() token.Pos {
+	if vd.Init != nil {
+		return vd.Init.End()
+	}
+	return vd.Id.End()
+}
+func (vd *VariableDeclarator) String() string {
+	if vd.Init != nil {
+		return vd.Id.String() + " = " + vd.Init.String()
+	}
+	return vd.Id.String()
+}
+func NewVariableDeclarator(id *Identifier, init Expression) *VariableDeclarator {
+	return &VariableDeclarator{
+		Type: "VariableDeclarator",
+		Id:   id,
+		Init: init,
+	}
+}
+
+type FunctionDeclaration struct {
+	Type   string
+	Token  token.Token // the 'def' token
+	Name   *Identifier
+	Params []*Identifier
+	Body   *BlockStatement
+}
+
+func (fd *FunctionDeclaration) statementNode() {}
+func (fd *FunctionDeclaration) Pos() token.Pos { return fd.Token.Span.Start }
+func (fd *FunctionDeclaration) End() token.Pos { return fd.Body.End() }
+func (fd *FunctionDeclaration) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("def ")
+	out.WriteString(fd.Name.String())
+	out.WriteString(paramsString(fd.Params))
+	out.WriteString(" {")
+	out.WriteString(fd.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+func NewFunctionDeclaration(t token.Token, name *Identifier, params []*Identifier, body *BlockStatement) *FunctionDeclaration {
+	return &FunctionDeclaration{
+		Type:   "FunctionDeclaration",
+		Token:  t,
+		Name:   name,
+		Params: params,
+		Body:   body,
+	}
+}
+
+// paramsString formats a parameter list as "(a, b)".
+func paramsString(params []*Identifier) string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	for i, param := range params {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(param.String())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type ReturnStatement struct {
+	Type     string
+	Token    token.Token // the 'return' token
+	Argument Expression  // nil for a bare return
+	EndToken token.Token // the terminating ';'
+}
+
+func (rs *ReturnStatement) statementNode() {}
+func (rs *ReturnStatement) Pos() token.Pos { return rs.Token.Span.Start }
+func (rs *ReturnStatement) End() token.Pos {
+	if rs.EndToken.Span.End.IsValid() {
+		return rs.EndToken.Span.End
+	}
+	if rs.Argument != nil {
+		return rs.Argument.End()
+	}
+	return rs.Token.Span.End
+}
+func (rs *ReturnStatement) String() string {
+	if rs.Argument != nil {
+		return "return " + rs.Argument.String() + ";"
+	}
+	return "return;"
+}
+func NewReturnStatement(t token.Token, argument Expression, end token.Token) *ReturnStatement {
+	return &ReturnStatement{
+		Type:     "ReturnStatement",
+		Token:    t,
+		Argument: argument,
+		EndToken: end,
+	}
+}
+
+type IfStatement struct {
+	Type       string
+	Token      token.Token // the 'if' token
+	Test       Expression
+	Consequent *BlockStatement
+	Alternate  Statement // nil, a *BlockStatement or an *IfStatement for 'else if'
+}
+
+func (is *IfStatement) statementNode() {}
+func (is *IfStatement) Pos() token.Pos { return is.Token.Span.Start }
+func (is *IfStatement) End() token.Pos {
+	if is.Alternate != nil {
+		return is.Alternate.End()
+	}
+	return is.Consequent.End()
+}
+func (is *IfStatement) String() string {
+	return ifString(is.Test, is.Consequent, is.Alternate)
+}
+func NewIfStatement(t token.Token, test Expression, consequent *BlockStatement, alternate Statement) *IfStatement {
+	return &IfStatement{
+		Type:       "IfStatement",
+		Token:      t,
+		Test:       test,
+		Consequent: consequent,
+		Alternate:  alternate,
+	}
+}
+
+// ifString formats a conditional as "if (test) {...} else {...}".
+func ifString(test Expression, consequent *BlockStatement, alternate Node) string {
+	var out bytes.Buffer
+
+	out.WriteString("if (")
+	out.WriteString(test.String())
+	out.WriteString(") {")
+	out.WriteString(consequent.String())
+	out.WriteString("}")
+
+	switch alt := alternate.(type) {
+	case nil:
+	case *BlockStatement:
+		out.WriteString(" else {")
+		out.WriteString(alt.String())
+		out.WriteString("}")
+	default:
+		out.WriteString(" else ")
+		out.WriteString(alt.String())
+	}
+
+	return out.String()
+}
+
+type WhileStatement struct {
+	Type  string
+	Token token.Token // the 'while' token
+	Test  Expression
+	Body  *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) Pos() token.Pos { return ws.Token.Span.Start }
+func (ws *WhileStatement) End() token.Pos { return ws.Body.End() }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while (")
+	out.WriteString(ws.Test.String())
+	out.WriteString(") {")
+	out.WriteString(ws.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+func NewWhileStatement(t token.Token, test Expression, body *BlockStatement) *WhileStatement {
+	return &WhileStatement{
+		Type:  "WhileStatement",
+		Token: t,
+		Test:  test,
+		Body:  body,
+	}
+}
+
+// ForStatement is a C-style 'for (init; test; update)' loop. Each of the
+// three clauses may be left out, in which case it is nil.
+type ForStatement struct {
+	Type   string
+	Token  token.Token // the 'for' token
+	Init   Node        // nil, a *VariableDeclaration or an Expression
+	Test   Expression
+	Update Expression
+	Body   *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) Pos() token.Pos { return fs.Token.Span.Start }
+func (fs *ForStatement) End() token.Pos { return fs.Body.End() }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	switch init := fs.Init.(type) {
+	case nil:
+		out.WriteString(";")
+	case *VariableDeclaration:
+		out.WriteString(init.String())
+	default:
+		out.WriteString(init.String())
+		out.WriteString(";")
+	}
+	if fs.Test != nil {
+		out.WriteString(" ")
+		out.WriteString(fs.Test.String())
+	}
+	out.WriteString(";")
+	if fs.Update != nil {
+		out.WriteString(" ")
+		out.WriteString(fs.Update.String())
+	}
+	out.WriteString(") {")
+	out.WriteString(fs.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+func NewForStatement(t token.Token, init Node, test Expression, update Expression, body *BlockStatement) *ForStatement {
+	return &ForStatement{
+		Type:   "ForStatement",
+		Token:  t,
+		Init:   init,
+		Test:   test,
+		Update: update,
+		Body:   body,
+	}
+}
+
+// ForInStatement is a 'for (x in iterable)' loop.
+type ForInStatement struct {
+	Type  string
+	Token token.Token // the 'for' token
+	Left  Expression  // an *Identifier, or a *BadExpression for an invalid variable
+	Right Expression
+	Body  *BlockStatement
+}
+
+func (fs *ForInStatement) statementNode() {}
+func (fs *ForInStatement) Pos() token.Pos { return fs.Token.Span.Start }
+func (fs *ForInStatement) End() token.Pos { return fs.Body.End() }
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fs.Left.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Right.String())
+	out.WriteString(") {")
+	out.WriteString(fs.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+func NewForInStatement(t token.Token, left Expression, right Expression, body *BlockStatement) *ForInStatement {
+	return &ForInStatement{
+		Type:  "ForInStatement",
 		Token: t,
-		Body:  stmts,
+		Left:  left,
+		Right: right,
+		Body:  body,
+	}
+}
+
+type BreakStatement struct {
+	Type     string
+	Token    token.Token // the 'break' token
+	EndToken token.Token // the terminating ';'
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) Pos() token.Pos { return bs.Token.Span.Start }
+func (bs *BreakStatement) End() token.Pos {
+	if bs.EndToken.Span.End.IsValid() {
+		return bs.EndToken.Span.End
+	}
+	return bs.Token.Span.End
+}
+func (bs *BreakStatement) String() string { return "break;" }
+func NewBreakStatement(t token.Token, end token.Token) *BreakStatement {
+	return &BreakStatement{
+		Type:     "BreakStatement",
+		Token:    t,
+		EndToken: end,
+	}
+}
+
+type ContinueStatement struct {
+	Type     string
+	Token    token.Token // the 'continue' token
+	EndToken token.Token // the terminating ';'
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) Pos() token.Pos { return cs.Token.Span.Start }
+func (cs *ContinueStatement) End() token.Pos {
+	if cs.EndToken.Span.End.IsValid() {
+		return cs.EndToken.Span.End
+	}
+	return cs.Token.Span.End
+}
+func (cs *ContinueStatement) String() string { return "continue;" }
+func NewContinueStatement(t token.Token, end token.Token) *ContinueStatement {
+	return &ContinueStatement{
+		Type:     "ContinueStatement",
+		Token:    t,
+		EndToken: end,
+	}
+}
+
+// EmptyStatement is a lone ';', such as the one that may follow a block
+// statement: if (a) { 1 } else { 2 };
+type EmptyStatement struct {
+	Type  string
+	Token token.Token // the ';' token
+}
+
+func (es *EmptyStatement) statementNode() {}
+func (es *EmptyStatement) Pos() token.Pos { return es.Token.Span.Start }
+func (es *EmptyStatement) End() token.Pos { return es.Token.Span.End }
+func (es *EmptyStatement) String() string { return ";" }
+func NewEmptyStatement(t token.Token) *EmptyStatement {
+	return &EmptyStatement{
+		Type:  "EmptyStatement",
+		Token: t,
+	}
+}
+
+// BadStatement is a placeholder for source text that could not be parsed as
+// a statement. It spans the tokens skipped while recovering.
+type BadStatement struct {
+	Type string
+	From token.Pos
+	To   token.Pos
+}
+
+func (bs *BadStatement) statementNode() {}
+func (bs *BadStatement) Pos() token.Pos { return bs.From }
+func (bs *BadStatement) End() token.Pos { return bs.To }
+func (bs *BadStatement) String() string { return "<bad statement>" }
+func NewBadStatement(from token.Pos, to token.Pos) *BadStatement {
+	return &BadStatement{
+		Type: "BadStatement",
+		From: from,
+		To:   to,
+	}
+}
+
+// BadExpression is a placeholder for an expression that could not be parsed.
+type BadExpression struct {
+	Type string
+	From token.Pos
+	To   token.Pos
+}
+
+func (be *BadExpression) expressionNode() {}
+func (be *BadExpression) Pos() token.Pos  { return be.From }
+func (be *BadExpression) End() token.Pos  { return be.To }
+func (be *BadExpression) String() string  { return "<bad expression>" }
+func NewBadExpression(from token.Pos, to token.Pos) *BadExpression {
+	return &BadExpression{
+		Type: "BadExpression",
+		From: from,
+		To:   to,
+	}
+}
+
+// AssignmentExpression is a simple (=) or compound (+=, -=, ...) assignment.
+type AssignmentExpression struct {
+	Type     string
+	Left     Expression // the assignment target
+	Operator string
+	Right    Expression
+}
+
+func (ae *AssignmentExpression) expressionNode() {}
+func (ae *AssignmentExpression) Pos() token.Pos  { return ae.Left.Pos() }
+func (ae *AssignmentExpression) End() token.Pos  { return ae.Right.End() }
+func (ae *AssignmentExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Left.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+func NewAssignmentExpression(o string, l Expression, r Expression) *AssignmentExpression {
+	return &AssignmentExpression{
+		Type:     "AssignmentExpression",
+		Left:     l,
+		Operator: o,
+		Right:    r,
 	}
 }
 
@@ -96,6 +575,8 @@ type BinaryExpression struct {
 }
 
 func (be *BinaryExpression) expressionNode() {}
+func (be *BinaryExpression) Pos() token.Pos  { return be.Left.Pos() }
+func (be *BinaryExpression) End() token.Pos  { return be.Right.End() }
 func (be *BinaryExpression) String() string {
 	var out bytes.Buffer
 
@@ -116,6 +597,282 @@ func NewBinaryExpression(o string, l Expression, r Expression) *BinaryExpression
 	}
 }
 
+// LogicalExpression is a && or || expression. It is kept apart from
+// BinaryExpression because its right operand is only evaluated when the left
+// one does not decide the result.
+type LogicalExpression struct {
+	Type     string
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (le *LogicalExpression) expressionNode() {}
+func (le *LogicalExpression) Pos() token.Pos  { return le.Left.Pos() }
+func (le *LogicalExpression) End() token.Pos  { return le.Right.End() }
+func (le *LogicalExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(le.Left.String())
+	out.WriteString(" " + le.Operator + " ")
+	out.WriteString(le.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+func NewLogicalExpression(o string, l Expression, r Expression) *LogicalExpression {
+	return &LogicalExpression{
+		Type:     "LogicalExpression",
+		Left:     l,
+		Operator: o,
+		Right:    r,
+	}
+}
+
+type UnaryExpression struct {
+	Type     string
+	Token    token.Token // the operator token
+	Operator string
+	Argument Expression
+}
+
+func (ue *UnaryExpression) expressionNode() {}
+func (ue *UnaryExpression) Pos() token.Pos  { return ue.Token.Span.Start }
+func (ue *UnaryExpression) End() token.Pos  { return ue.Argument.End() }
+func (ue *UnaryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ue.Operator)
+	out.WriteString(ue.Argument.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+func NewUnaryExpression(t token.Token, argument Expression) *UnaryExpression {
+	return &UnaryExpression{
+		Type:     "UnaryExpression",
+		Token:    t,
+		Operator: t.Literal,
+		Argument: argument,
+	}
+}
+
+// IfExpression is a conditional used as a value. The value of a branch is
+// the value of the last expression statement in its block.
+type IfExpression struct {
+	Type       string
+	Token      token.Token // the 'if' token
+	Test       Expression
+	Consequent *BlockStatement
+	Alternate  Node // nil, a *BlockStatement or an *IfExpression for 'else if'
+}
+
+func (ie *IfExpression) expressionNode() {}
+func (ie *IfExpression) Pos() token.Pos  { return ie.Token.Span.Start }
+func (ie *IfExpression) End() token.Pos {
+	if ie.Alternate != nil {
+		return ie.Alternate.End()
+	}
+	return ie.Consequent.End()
+}
+func (ie *IfExpression) String() string {
+	return ifString(ie.Test, ie.Consequent, ie.Alternate)
+}
+func NewIfExpression(t token.Token, test Expression, consequent *BlockStatement, alternate Node) *IfExpression {
+	return &IfExpression{
+		Type:       "IfExpression",
+		Token:      t,
+		Test:       test,
+		Consequent: consequent,
+		Alternate:  alternate,
+	}
+}
+
+// FunctionLiteral is an anonymous function, either 'def(a, b) { ... }' or
+// the arrow form '(a, b) -> a + b'.
+type FunctionLiteral struct {
+	Type   string
+	Token  token.Token // the 'def' token, or the '(' of an arrow function
+	Params []*Identifier
+	Body   Node // a *BlockStatement, or an Expression for an arrow function
+}
+
+func (fl *FunctionLiteral) expressionNode() {}
+func (fl *FunctionLiteral) Pos() token.Pos  { return fl.Token.Span.Start }
+func (fl *FunctionLiteral) End() token.Pos  { return fl.Body.End() }
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	if fl.Token.Type == token.FUNCTION {
+		out.WriteString("def")
+	}
+	out.WriteString(paramsString(fl.Params))
+	if fl.Token.Type != token.FUNCTION {
+		out.WriteString(" ->")
+	}
+
+	if body, ok := fl.Body.(*BlockStatement); ok {
+		out.WriteString(" {")
+		out.WriteString(body.String())
+		out.WriteString("}")
+	} else {
+		out.WriteString(" ")
+		out.WriteString(fl.Body.String())
+	}
+
+	return out.String()
+}
+func NewFunctionLiteral(t token.Token, params []*Identifier, body Node) *FunctionLiteral {
+	return &FunctionLiteral{
+		Type:   "FunctionLiteral",
+		Token:  t,
+		Params: params,
+		Body:   body,
+	}
+}
+
+type CallExpression struct {
+	Type      string
+	Token     token.Token // the '(' token
+	Callee    Expression
+	Arguments []Expression
+	EndToken  token.Token // the ')' token
+}
+
+func (ce *CallExpression) expressionNode() {}
+func (ce *CallExpression) Pos() token.Pos  { return ce.Callee.Pos() }
+func (ce *CallExpression) End() token.Pos  { return ce.EndToken.Span.End }
+func (ce *CallExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ce.Callee.String())
+	out.WriteString("(")
+	for i, arg := range ce.Arguments {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(arg.String())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+func NewCallExpression(t token.Token, callee Expression, args []Expression, end token.Token) *CallExpression {
+	return &CallExpression{
+		Type:      "CallExpression",
+		Token:     t,
+		Callee:    callee,
+		Arguments: args,
+		EndToken:  end,
+	}
+}
+
+// MemberExpression is a.b, or the computed form a[b] when Computed is set,
+// in which case Property is the key or index between the brackets, as in
+// m["key"] or xs[i].
+type MemberExpression struct {
+	Type     string
+	Token    token.Token // the '.' or '[' token
+	Object   Expression
+	Property Expression
+	Computed bool
+	EndToken token.Token // the ']' token of a computed access
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) Pos() token.Pos  { return me.Object.Pos() }
+func (me *MemberExpression) End() token.Pos {
+	if me.Computed {
+		return me.EndToken.Span.End
+	}
+	return me.Property.End()
+}
+func (me *MemberExpression) String() string {
+	if me.Computed {
+		return me.Object.String() + "[" + me.Property.String() + "]"
+	}
+	return me.Object.String() + "." + me.Property.String()
+}
+func NewMemberExpression(t token.Token, object Expression, property *Identifier) *MemberExpression {
+	return &MemberExpression{
+		Type:     "MemberExpression",
+		Token:    t,
+		Object:   object,
+		Property: property,
+	}
+}
+func NewComputedMemberExpression(t token.Token, object Expression, property Expression, end token.Token) *MemberExpression {
+	return &MemberExpression{
+		Type:     "MemberExpression",
+		Token:    t,
+		Object:   object,
+		Property: property,
+		Computed: true,
+		EndToken: end,
+	}
+}
+
+// SliceExpression is xs[low:high]. Either bound may be left out, in which
+// case it is nil.
+type SliceExpression struct {
+	Type     string
+	Token    token.Token // the '[' token
+	Object   Expression
+	Low      Expression
+	High     Expression
+	EndToken token.Token // the ']' token
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) Pos() token.Pos  { return se.Object.Pos() }
+func (se *SliceExpression) End() token.Pos  { return se.EndToken.Span.End }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(se.Object.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("]")
+
+	return out.String()
+}
+func NewSliceExpression(t token.Token, object Expression, low Expression, high Expression, end token.Token) *SliceExpression {
+	return &SliceExpression{
+		Type:     "SliceExpression",
+		Token:    t,
+		Object:   object,
+		Low:      low,
+		High:     high,
+		EndToken: end,
+	}
+}
+
+type Identifier struct {
+	Type  string
+	Token token.Token
+	Name  string
+}
+
+func (i *Identifier) expressionNode() {}
+func (i *Identifier) Pos() token.Pos  { return i.Token.Span.Start }
+func (i *Identifier) End() token.Pos  { return i.Token.Span.End }
+func (i *Identifier) String() string  { return i.Name }
+func NewIdentifier(t token.Token) *Identifier {
+	return &Identifier{
+		Type:  "Identifier",
+		Token: t,
+		Name:  t.Literal,
+	}
+}
+
 type IntegerLiteral struct {
 	Type  string
 	Token token.Token
@@ -123,6 +880,8 @@ type IntegerLiteral struct {
 }
 
 func (il *IntegerLiteral) expressionNode() {}
+func (il *IntegerLiteral) Pos() token.Pos  { return il.Token.Span.Start }
+func (il *IntegerLiteral) End() token.Pos  { return il.Token.Span.End }
 func (il *IntegerLiteral) String() string  { return il.Token.Literal }
 func NewIntegerLiteral(t token.Token, value int64) *IntegerLiteral {
 	return &IntegerLiteral{
@@ -139,6 +898,8 @@ type FloatLiteral struct {
 }
 
 func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) Pos() token.Pos  { return fl.Token.Span.Start }
+func (fl *FloatLiteral) End() token.Pos  { return fl.Token.Span.End }
 func (fl *FloatLiteral) String() string  { return fl.Token.Literal }
 func NewFloatLiteral(t token.Token, value float64) *FloatLiteral {
 	return &FloatLiteral{
@@ -155,6 +916,8 @@ type StringLiteral struct {
 }
 
 func (sl *StringLiteral) expressionNode() {}
+func (sl *StringLiteral) Pos() token.Pos  { return sl.Token.Span.Start }
+func (sl *StringLiteral) End() token.Pos  { return sl.Token.Span.End }
 func (sl *StringLiteral) String() string  { return sl.Token.Literal }
 func NewStringLiteral(t token.Token, value string) *StringLiteral {
 	return &StringLiteral{
@@ -163,3 +926,177 @@ func NewStringLiteral(t token.Token, value string) *StringLiteral {
 		Value: value,
 	}
 }
+
+type InterpolatedString struct {
+	Type     string
+	Token    token.Token  // the STRING_HEAD token
+	Parts    []Expression // text fragments and placeholder expressions, in source order
+	EndToken token.Token  // the STRING_TAIL token, empty where the string broke off
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) Pos() token.Pos  { return is.Token.Span.Start }
+func (is *InterpolatedString) End() token.Pos  { return is.EndToken.Span.End }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	// The source text of each segment is written as is, like the literal of a
+	// StringLiteral. Segments without text have no fragment in Parts, so a
+	// placeholder not followed by one is closed by an empty "}${" segment or
+	// by the tail.
+	out.WriteString(is.Token.Literal)
+	for i, part := range is.Parts {
+		if isFragment(part) {
+			if tok := part.(*StringLiteral).Token; tok.Type != token.STRING_HEAD {
+				out.WriteString(tok.Literal)
+			}
+			continue
+		}
+
+		out.WriteString(part.String())
+		if i+1 < len(is.Parts) && !isFragment(is.Parts[i+1]) {
+			out.WriteString("}${")
+		} else if i+1 == len(is.Parts) {
+			out.WriteString(is.EndToken.Literal)
+		}
+	}
+
+	return out.String()
+}
+
+// isFragment tells whether a part of an interpolated string is a text
+// fragment built from a segment token, as opposed to a string literal written
+// inside a placeholder.
+func isFragment(part Expression) bool {
+	sl, ok := part.(*StringLiteral)
+	return ok && sl.Token.Type != token.STRING
+}
+func NewInterpolatedString(t token.Token, parts []Expression, end token.Token) *InterpolatedString {
+	return &InterpolatedString{
+		Type:     "InterpolatedString",
+		Token:    t,
+		Parts:    parts,
+		EndToken: end,
+	}
+}
+
+type ArrayLiteral struct {
+	Type     string
+	Token    token.Token // the '[' token
+	Elements []Expression
+	EndToken token.Token // the ']' token
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) Pos() token.Pos  { return al.Token.Span.Start }
+func (al *ArrayLiteral) End() token.Pos  { return al.EndToken.Span.End }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("[")
+	for i, el := range al.Elements {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(el.String())
+	}
+	out.WriteString("]")
+
+	return out.String()
+}
+func NewArrayLiteral(t token.Token, elements []Expression, end token.Token) *ArrayLiteral {
+	return &ArrayLiteral{
+		Type:     "ArrayLiteral",
+		Token:    t,
+		Elements: elements,
+		EndToken: end,
+	}
+}
+
+type MapLiteral struct {
+	Type     string
+	Token    token.Token // the '{' token
+	Entries  []*MapEntry // in source order
+	EndToken token.Token // the '}' token
+}
+
+func (ml *MapLiteral) expressionNode() {}
+func (ml *MapLiteral) Pos() token.Pos  { return ml.Token.Span.Start }
+func (ml *MapLiteral) End() token.Pos  { return ml.EndToken.Span.End }
+func (ml *MapLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("{")
+	for i, entry := range ml.Entries {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(entry.String())
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+func NewMapLiteral(t token.Token, entries []*MapEntry, end token.Token) *MapLiteral {
+	return &MapLiteral{
+		Type:     "MapLiteral",
+		Token:    t,
+		Entries:  entries,
+		EndToken: end,
+	}
+}
+
+// MapEntry is a 'key: value' pair of a map literal. The key is an *Identifier
+// or a *StringLiteral.
+type MapEntry struct {
+	Type  string
+	Key   Expression
+	Value Expression
+}
+
+func (me *MapEntry) Pos() token.Pos { return me.Key.Pos() }
+func (me *MapEntry) End() token.Pos { return me.Value.End() }
+func (me *MapEntry) String() string {
+	return me.Key.String() + ": " + me.Value.String()
+}
+func NewMapEntry(key Expression, value Expression) *MapEntry {
+	return &MapEntry{
+		Type:  "MapEntry",
+		Key:   key,
+		Value: value,
+	}
+}
+
+type BooleanLiteral struct {
+	Type  string
+	Token token.Token
+	Value bool
+}
+
+func (bl *BooleanLiteral) expressionNode() {}
+func (bl *BooleanLiteral) Pos() token.Pos  { return bl.Token.Span.Start }
+func (bl *BooleanLiteral) End() token.Pos  { return bl.Token.Span.End }
+func (bl *BooleanLiteral) String() string  { return bl.Token.Literal }
+func NewBooleanLiteral(t token.Token, value bool) *BooleanLiteral {
+	return &BooleanLiteral{
+		Type:  "BooleanLiteral",
+		Token: t,
+		Value: value,
+	}
+}
+
+type NilLiteral struct {
+	Type  string
+	Token token.Token
+}
+
+func (nl *NilLiteral) expressionNode() {}
+func (nl *NilLiteral) Pos() token.Pos  { return nl.Token.Span.Start }
+func (nl *NilLiteral) End() token.Pos  { return nl.Token.Span.End }
+func (nl *NilLiteral) String() string  { return nl.Token.Literal }
+func NewNilLiteral(t token.Token) *NilLiteral {
+	return &NilLiteral{
+		Type:  "NilLiteral",
+		Token: t,
+	}
+}
@@ -4,12 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 
 	"github.com/TwiN/go-color"
 	"github.com/jellycat-io/gero/lexer"
 	"github.com/jellycat-io/gero/parser"
+	"github.com/jellycat-io/gero/token"
 	"github.com/jellycat-io/gero/util"
 	"github.com/spf13/cobra"
 )
@@ -29,18 +29,36 @@ var runCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		buf, err := ioutil.ReadFile(filepath)
+		file, err := os.Open(filepath)
 		if err != nil {
 			fmt.Printf(color.InRed("cannot read file: %q"), filepath)
+			os.Exit(1)
+		}
+		defer file.Close()
+
+		l := lexer.NewReader(filepath, file)
+
+		if showTokens, _ := cmd.Flags().GetBool("tokens"); showTokens {
+			tokens := []token.Token{}
+			for it := l.Tokens(); it.Next(); {
+				tokens = append(tokens, it.Token())
+			}
+			if len(l.Errors()) != 0 {
+				util.PrintDiagnostics(out, l.Errors())
+			}
+			json, err := json.MarshalIndent(tokens, "", "    ")
+			if err != nil {
+				io.WriteString(out, err.Error())
+			}
+			io.WriteString(out, string(json))
+			return
 		}
-		source := string(buf)
 
-		l := lexer.New(source)
 		p := parser.New(l)
 
 		program := p.Program()
 		if len(p.Errors()) != 0 {
-			util.PrintParserErrors(out, p.Errors())
+			util.PrintDiagnostics(out, p.Errors())
 		}
 		json, err := json.MarshalIndent(program, "", "    ")
 		if err != nil {
@@ -62,4 +80,5 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// runCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	runCmd.Flags().Bool("tokens", false, "Print the token stream instead of the syntax tree")
 }
@@ -0,0 +1,31 @@
+package diag
+
+// Code identifies a kind of diagnostic. Codes are stable across releases, so
+// tools and tests can rely on them rather than on message texts.
+type Code string
+
+// Lexical errors
+const (
+	UnexpectedCharacter Code = "E0001"
+	UnterminatedString  Code = "E0002"
+	UnterminatedComment Code = "E0003"
+	InvalidNumber       Code = "E0004"
+	InvalidEscape       Code = "E0005"
+	ReadError           Code = "E0006"
+)
+
+// Syntax errors
+const (
+	UnexpectedToken    Code = "E0100"
+	UnclosedDelimiter  Code = "E0101"
+	UnmatchedDelimiter Code = "E0102"
+	InvalidMapKey      Code = "E0103"
+)
+
+// Errors in well-formed syntax
+const (
+	InvalidAssignmentTarget Code = "E0200"
+	InvalidLoopVariable     Code = "E0201"
+	JumpOutsideLoop         Code = "E0202"
+	NumberOutOfRange        Code = "E0203"
+)
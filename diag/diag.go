@@ -0,0 +1,141 @@
+package diag
+
+import (
+	"fmt"
+
+	"github.com/jellycat-io/gero/token"
+)
+
+// Severity tells how serious a diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Hint
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Hint:
+		return "hint"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic is a problem found in a source file by the lexer, the parser or
+// a later phase.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Message  string
+	Span     token.Span // the primary location of the problem
+
+	Labels []Label  `json:",omitempty"`
+	Notes  []string `json:",omitempty"`
+	Fixes  []Fix    `json:",omitempty"`
+}
+
+// Label points at a secondary location related to a diagnostic, such as the
+// opening parenthesis of an unclosed group.
+type Label struct {
+	Span    token.Span
+	Message string
+}
+
+// Fix is a suggested edit that resolves a diagnostic: the text in Span is
+// replaced by Replacement. An empty span inserts, an empty replacement
+// deletes.
+type Fix struct {
+	Message     string
+	Span        token.Span
+	Replacement string
+}
+
+// Errorf creates an error diagnostic with a formatted message.
+func Errorf(code Code, span token.Span, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Span:     span,
+	}
+}
+
+// WithLabel returns a copy of d with a secondary label added.
+func (d Diagnostic) WithLabel(span token.Span, message string) Diagnostic {
+	d.Labels = append(d.Labels[:len(d.Labels):len(d.Labels)], Label{Span: span, Message: message})
+	return d
+}
+
+// WithNote returns a copy of d with a note added.
+func (d Diagnostic) WithNote(note string) Diagnostic {
+	d.Notes = append(d.Notes[:len(d.Notes):len(d.Notes)], note)
+	return d
+}
+
+// WithFix returns a copy of d with a suggested fix added.
+func (d Diagnostic) WithFix(message string, span token.Span, replacement string) Diagnostic {
+	d.Fixes = append(d.Fixes[:len(d.Fixes):len(d.Fixes)], Fix{Message: message, Span: span, Replacement: replacement})
+	return d
+}
+
+// String formats the diagnostic as "message at position".
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s at %s", d.Message, d.Span.Start)
+}
+
+// Error makes a diagnostic usable as an error value.
+func (d Diagnostic) Error() string {
+	return d.String()
+}
+
+// Collector gathers the diagnostics reported while processing a source, in
+// the order they are reported. The lexer creates one that the parser and
+// later phases report into as well.
+type Collector struct {
+	diagnostics []Diagnostic
+}
+
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Report records a diagnostic.
+func (c *Collector) Report(d Diagnostic) {
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+// Diagnostics returns every diagnostic reported so far.
+func (c *Collector) Diagnostics() []Diagnostic {
+	return c.diagnostics
+}
+
+// Errors returns the diagnostics of Error severity reported so far.
+func (c *Collector) Errors() []Diagnostic {
+	var errors []Diagnostic
+	for _, d := range c.diagnostics {
+		if d.Severity == Error {
+			errors = append(errors, d)
+		}
+	}
+	return errors
+}
+
+// HasErrors reports whether any diagnostic of Error severity was reported.
+func (c *Collector) HasErrors() bool {
+	for _, d := range c.diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
@@ -1,108 +1,747 @@
 package lexer
 
 import (
-	"fmt"
-	"regexp"
+	"io"
+	"unicode"
+	"unicode/utf8"
 
-	geroError "github.com/jellycat-io/gero/error"
+	"github.com/jellycat-io/gero/diag"
 	"github.com/jellycat-io/gero/token"
 )
 
-var specs = map[string]token.TokenType{
-	//-----------------------------------
-	// Skipped
-	"^\\s+":                   token.WHITESPACE,
-	"^\\t+":                   token.WHITESPACE,
-	"^\\n":                    token.NEWLINE,
-	"^\\/\\*[\\s\\S]*?\\*\\/": token.COMMENT,
-	"^\\/\\/.*":               token.COMMENT,
-	//-----------------------------------
-	// Symbols, delimiters
-	"^;":   token.SEMI,
-	"^{":   token.LBRACE,
-	"^}":   token.RBRACE,
-	"^\\(": token.LPAREN,
-	"^\\)": token.RPAREN,
-	//-----------------------------------
-	// Math operators
-	"^\\+": token.PLUS,
-	"^-":   token.MINUS,
-	"^\\*": token.ASTERISK,
-	"^\\/": token.SLASH,
-	"^%":   token.PERCENT,
-	//-----------------------------------
-	// Numbers
-	"^[0-9]*(\\.[0-9]+)": token.FLOAT,
-	"^\\d+":              token.INT,
-	//-----------------------------------
-	// Strings
-	`^"[^"]*"`: token.STRING,
-	`^'[^']*'`: token.STRING,
-}
-
 // Lazily pulls a token from a stream.
+//
+// The lexer is a single pass, character driven scanner: the current character
+// selects the rule to apply, and every rule consumes the longest lexeme it can.
+// Scanning the same input always yields the same token stream.
+//
+// Input is either a complete string or an io.Reader consumed in chunks as the
+// scan progresses. Either way the lexer only buffers the current token and the
+// bytes read ahead of it.
 type Lexer struct {
-	input  string
-	line   int
-	cursor int
+	input    []byte    // the current token and the bytes read after it
+	base     int       // offset of input[0] in the source
+	reader   io.Reader // source of further input, nil once exhausted
+	chunk    []byte
+	filename string
+	line     int
+	column   int
+	cursor   int       // offset of the next unread byte
+	start    token.Pos // position of the first byte of the current token
+	diags    *diag.Collector
+	mode     Mode
+
+	// interpolations holds the strings whose placeholders are being scanned,
+	// innermost last.
+	interpolations []interpolation
+}
+
+// interpolation is an interpolated string suspended at a "${" placeholder.
+type interpolation struct {
+	start     token.Pos // position of the opening quote
+	quote     string
+	multiline bool
+	braces    int // '{' opened inside the placeholder and not closed yet
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input, cursor: 0, line: 1}
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer whose token positions refer to the given file name.
+func NewFile(filename string, input string) *Lexer {
+	l := &Lexer{input: []byte(input), filename: filename, cursor: 0, line: 1, column: 1, diags: diag.NewCollector()}
 	return l
 }
 
-func (l *Lexer) NextToken() interface{} {
+// NewReader creates a lexer that scans r incrementally. Token positions refer
+// to the given name, and read errors are reported like lexical errors.
+func NewReader(name string, r io.Reader) *Lexer {
+	l := &Lexer{reader: r, filename: name, cursor: 0, line: 1, column: 1, diags: diag.NewCollector()}
+	return l
+}
+
+// Mode controls optional lexer behavior.
+type Mode uint
+
+const (
+	// ScanTrivia attaches whitespace, newlines and comments to the tokens
+	// around them instead of discarding them. Concatenating the leading
+	// trivia, literal and trailing trivia of every token rebuilds the source.
+	ScanTrivia Mode = 1 << iota
+)
+
+// SetMode enables the given optional behaviors for the tokens scanned next.
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
+// Errors returns the lexical errors found so far. Scanning never stops on an
+// error: the offending input is returned as an ILLEGAL token instead.
+func (l *Lexer) Errors() []diag.Diagnostic {
+	return l.diags.Errors()
+}
+
+// Diagnostics returns the collector the lexer reports into, which the parser
+// and later phases share.
+func (l *Lexer) Diagnostics() *diag.Collector {
+	return l.diags
+}
+
+func (l *Lexer) NextToken() token.Token {
+	leading := l.trivia(false)
+	tok := l.scan()
+
+	if l.mode&ScanTrivia != 0 {
+		tok.LeadingTrivia = leading
+		if tok.Type != token.EOF {
+			tok.TrailingTrivia = l.trivia(true)
+		}
+	}
+
+	return tok
+}
+
+// scan reads the token starting at the cursor.
+func (l *Lexer) scan() token.Token {
+	l.start = l.pos()
+
 	if !l.hasMoreTokens() {
-		return l.newToken(token.EOF, "")
+		for i := len(l.interpolations) - 1; i >= 0; i-- {
+			l.error(diag.UnterminatedString, l.span(l.interpolations[i].start), "Unterminated string")
+		}
+		l.interpolations = nil
+		return l.emit(token.EOF)
 	}
 
-	s := l.input[l.cursor:len(l.input)]
+	ch := l.peek()
 
-	for regex, tokenType := range specs {
-		value, ok := l.match(regex, s)
+	switch {
+	case isDigit(ch), ch == '.' && isDigit(l.peekAt(1)):
+		return l.number()
+	case ch == '"', ch == '\'', ch == '`':
+		return l.string()
+	case isLetter(l.peekRune()):
+		return l.identifier()
+	}
 
-		if !ok {
-			continue
+	if ch >= utf8.RuneSelf {
+		return l.illegal()
+	}
+
+	l.advance()
+
+	// Operators are matched with maximal munch: the longest operator starting
+	// at the current character wins.
+	switch ch {
+	case ';':
+		return l.emit(token.SEMI)
+	case ',':
+		return l.emit(token.COMMA)
+	case ':':
+		return l.emit(token.COLON)
+	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].braces++
+		}
+		return l.emit(token.LBRACE)
+	case '}':
+		if n := len(l.interpolations); n > 0 {
+			if l.interpolations[n-1].braces == 0 {
+				return l.stringContinuation()
+			}
+			l.interpolations[n-1].braces--
+		}
+		return l.emit(token.RBRACE)
+	case '(':
+		return l.emit(token.LPAREN)
+	case ')':
+		return l.emit(token.RPAREN)
+	case '[':
+		return l.emit(token.LBRACKET)
+	case ']':
+		return l.emit(token.RBRACKET)
+	case '.':
+		return l.emit(l.either('.', token.DOT_DOT, token.DOT))
+	case '=':
+		return l.emit(l.either('=', token.EQ, token.ASSIGN))
+	case '!':
+		return l.emit(l.either('=', token.NOT_EQ, token.BANG))
+	case '<':
+		return l.emit(l.either('=', token.LT_EQ, token.LT))
+	case '>':
+		return l.emit(l.either('=', token.GT_EQ, token.GT))
+	case '+':
+		return l.emit(l.either('=', token.PLUS_ASSIGN, token.PLUS))
+	case '-':
+		if l.match('>') {
+			return l.emit(token.ARROW)
+		}
+		return l.emit(l.either('=', token.MINUS_ASSIGN, token.MINUS))
+	case '*':
+		return l.emit(l.either('=', token.ASTERISK_ASSIGN, token.ASTERISK))
+	case '/':
+		return l.emit(l.either('=', token.SLASH_ASSIGN, token.SLASH))
+	case '%':
+		return l.emit(l.either('=', token.PERCENT_ASSIGN, token.PERCENT))
+	case '&':
+		if l.match('&') {
+			return l.emit(token.AND)
+		}
+	case '|':
+		if l.match('|') {
+			return l.emit(token.OR)
+		}
+	}
+
+	l.error(diag.UnexpectedCharacter, l.span(l.start), "Unexpected character %q", ch)
+	return l.emit(token.ILLEGAL)
+}
+
+// illegal consumes the current character, which no rule accepts, and reports
+// it.
+func (l *Lexer) illegal() token.Token {
+	r := l.peekRune()
+	l.advanceRune()
+	l.error(diag.UnexpectedCharacter, l.span(l.start), "Unexpected character %q", r)
+	return l.emit(token.ILLEGAL)
+}
+
+// trivia consumes the whitespace, newlines and comments at the cursor. For
+// trailing trivia it stops after the first newline. The pieces are only
+// returned in ScanTrivia mode, otherwise they are skipped.
+func (l *Lexer) trivia(trailing bool) []token.Trivia {
+	var trivia []token.Trivia
+
+	for {
+		// Skipped input does not need to stay buffered.
+		l.start = l.pos()
+
+		if !l.hasMoreTokens() {
+			return trivia
+		}
+
+		var triviaType token.TokenType
+
+		switch ch := l.peek(); {
+		case l.atNewline():
+			if ch == '\r' {
+				l.advance()
+			}
+			l.advance()
+			triviaType = token.NEWLINE
+		case isWhitespace(ch):
+			for isWhitespace(l.peek()) && !l.atNewline() {
+				l.advance()
+			}
+			triviaType = token.WHITESPACE
+		case ch == '/' && l.peekAt(1) == '/':
+			for l.hasMoreTokens() && !l.atNewline() {
+				l.advance()
+			}
+			triviaType = token.COMMENT
+		case ch == '/' && l.peekAt(1) == '*':
+			l.blockComment()
+			triviaType = token.COMMENT
+		default:
+			return trivia
+		}
+
+		if l.mode&ScanTrivia != 0 {
+			trivia = append(trivia, token.Trivia{
+				Type:    triviaType,
+				Literal: l.text(),
+				Span:    token.Span{Start: l.start, End: l.pos()},
+			})
+		}
+
+		if trailing && triviaType == token.NEWLINE {
+			return trivia
+		}
+	}
+}
+
+// atNewline reports whether the cursor is at a "\n" or "\r\n" line break.
+func (l *Lexer) atNewline() bool {
+	return l.peek() == '\n' || l.peek() == '\r' && l.peekAt(1) == '\n'
+}
+
+func (l *Lexer) blockComment() {
+	start := l.pos()
+	l.advance()
+	l.advance()
+
+	for l.hasMoreTokens() {
+		if l.peek() == '*' && l.peekAt(1) == '/' {
+			l.advance()
+			l.advance()
+			return
+		}
+		l.advance()
+	}
+
+	l.error(diag.UnterminatedComment, l.span(start), "Unterminated comment")
+}
+
+/**
+ * Identifier
+ * 	: LETTER (LETTER | DIGIT)*
+ * 	;
+ *
+ * Letters are Unicode letters and '_', digits are Unicode decimal digits.
+ * Keywords are scanned as identifiers and then resolved by token.LookupIdent.
+ */
+func (l *Lexer) identifier() token.Token {
+	for {
+		r := l.peekRune()
+		if !isLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		l.advanceRune()
+	}
+
+	tok := l.emit(token.IDENT)
+	tok.Type = token.LookupIdent(tok.Literal)
+	return tok
+}
+
+/**
+ * Number
+ * 	: '0x' HEX_DIGITS
+ * 	| '0o' OCTAL_DIGITS
+ * 	| '0b' BINARY_DIGITS
+ * 	| DIGITS ('.' DIGITS?)? EXPONENT?
+ * 	| '.' DIGITS EXPONENT?
+ * 	;
+ *
+ * EXPONENT
+ * 	: ('e' | 'E') ('+' | '-')? DIGITS
+ * 	;
+ *
+ * Digits may be separated by single underscores, as in 1_000_000. A number
+ * with a fraction or an exponent is a FLOAT, any other is an INT. Malformed
+ * numbers are reported and returned as ILLEGAL.
+ */
+func (l *Lexer) number() token.Token {
+	tokenType := token.TokenType(token.INT)
+	valid := true
+
+	if base := prefixBase(l.peekAt(1)); l.peek() == '0' && base != 0 {
+		l.advance()
+		l.advance()
+		n, ok := l.digits(base)
+		if n == 0 && ok {
+			l.error(diag.InvalidNumber, l.span(l.start), "Missing digits after %q", l.text())
 		}
+		valid = n > 0 && ok
+	} else {
+		_, ok := l.digits(10)
+		valid = ok
 
-		if tokenType == token.NEWLINE {
-			// TODO: Fix line increment
-			l.line++
-			return l.NextToken()
+		// A '.' followed by another '.' or by a letter belongs to the next
+		// token, as in 1..5 or 5.method(), unless the letter starts an
+		// exponent, as in 5.e3.
+		if l.peek() == '.' && l.peekAt(1) != '.' && (!isLetter(l.peekRuneAt(1)) || l.exponentAt(1)) {
+			tokenType = token.FLOAT
+			l.advance()
+			_, ok := l.digits(10)
+			valid = valid && ok
 		}
 
-		if tokenType == token.WHITESPACE || tokenType == token.COMMENT {
-			return l.NextToken()
+		if l.exponentAt(0) {
+			tokenType = token.FLOAT
+			l.advance()
+			if l.peek() == '+' || l.peek() == '-' {
+				l.advance()
+			}
+			_, ok := l.digits(10)
+			valid = valid && ok
 		}
+	}
+
+	// Letters or digits running into the number make it malformed, as in
+	// 0b102 or 12abc.
+	if r := l.peekRune(); isLetter(r) || unicode.IsDigit(r) {
+		for r := l.peekRune(); isLetter(r) || unicode.IsDigit(r); r = l.peekRune() {
+			l.advanceRune()
+		}
+		if valid {
+			l.error(diag.InvalidNumber, l.span(l.start), "Invalid number literal %q", l.text())
+		}
+		valid = false
+	}
+
+	if !valid {
+		return l.emit(token.ILLEGAL)
+	}
+	return l.emit(tokenType)
+}
 
-		return l.newToken(tokenType, value)
+// exponentAt tells whether an exponent, 'e' or 'E' with an optional sign and
+// a digit, starts n bytes ahead.
+func (l *Lexer) exponentAt(n int) bool {
+	if exp := l.peekAt(n); exp != 'e' && exp != 'E' {
+		return false
+	}
+	next := l.peekAt(n + 1)
+	if next == '+' || next == '-' {
+		next = l.peekAt(n + 2)
 	}
+	return isDigit(next)
+}
 
-	geroError.PrintError(geroError.SYNTAX_ERROR, fmt.Sprintf(
-		`Syntax error: Unexpected token "%s" at line %d`,
-		string(s[0]),
-		l.line,
-	))
-	return nil
+// digits consumes a run of digits in the given base, with single '_'
+// separators between them, and returns how many digits it read. ok is false
+// if a misplaced separator was reported.
+func (l *Lexer) digits(base int) (n int, ok bool) {
+	ok = true
+	for {
+		ch := l.peek()
+		if ch == '_' {
+			start := l.pos()
+			l.advance()
+			if n == 0 || !isDigitOf(l.peek(), base) {
+				l.error(diag.InvalidNumber, l.span(start), "'_' must separate successive digits")
+				ok = false
+			}
+			continue
+		}
+		if !isDigitOf(ch, base) {
+			return n, ok
+		}
+		l.advance()
+		n++
+	}
 }
 
+/**
+ * String
+ * 	: '"' (CHAR | ESCAPE | PLACEHOLDER)* '"'
+ * 	| "'" (CHAR | ESCAPE | PLACEHOLDER)* "'"
+ * 	| '"""' (CHAR | NEWLINE | ESCAPE | PLACEHOLDER)* '"""'
+ * 	| '`' (CHAR | NEWLINE)* '`'
+ * 	;
+ *
+ * PLACEHOLDER
+ * 	: '${' Expression '}'
+ * 	;
+ *
+ * Quoted strings end at the first newline, triple-quoted and backtick (raw)
+ * strings may span lines. Escape sequences are checked here and decoded by
+ * Unquote.
+ *
+ * A string with placeholders is returned in segments: the text up to the
+ * first "${" is a STRING_HEAD, the placeholder expressions are scanned as
+ * ordinary tokens, and the text after each closing '}' is a STRING_MIDDLE
+ * or, for the last one, a STRING_TAIL.
+ */
+func (l *Lexer) string() token.Token {
+	var quote string
+	multiline := true
+
+	switch {
+	case l.peek() == '`':
+		l.advance()
+		l.scanUntil(l.start, "`", true, false)
+		return l.stringToken(token.STRING, "`")
+	case l.lookingAt(`"""`):
+		quote = `"""`
+	default:
+		quote = string(l.peek())
+		multiline = false
+	}
+
+	for range quote {
+		l.advance()
+	}
+
+	if l.scanUntil(l.start, quote, multiline, true) {
+		l.interpolations = append(l.interpolations, interpolation{
+			start:     l.start,
+			quote:     quote,
+			multiline: multiline,
+		})
+		return l.stringToken(token.STRING_HEAD, quote)
+	}
+
+	return l.stringToken(token.STRING, quote)
+}
+
+// stringContinuation resumes the innermost interpolated string at the '}'
+// closing one of its placeholders.
+func (l *Lexer) stringContinuation() token.Token {
+	s := l.interpolations[len(l.interpolations)-1]
+
+	if l.scanUntil(s.start, s.quote, s.multiline, true) {
+		return l.stringToken(token.STRING_MIDDLE, s.quote)
+	}
+
+	l.interpolations = l.interpolations[:len(l.interpolations)-1]
+	return l.stringToken(token.STRING_TAIL, s.quote)
+}
+
+// stringToken emits a string or string segment and reports its invalid escape
+// sequences.
+func (l *Lexer) stringToken(tokenType token.TokenType, quote string) token.Token {
+	tok := l.emit(tokenType)
+
+	var errs []escapeError
+	if tokenType == token.STRING {
+		_, errs = unquote(tok.Literal)
+	} else {
+		_, errs = unquoteSegment(tok, quote)
+	}
+
+	for _, err := range errs {
+		span := token.Span{Start: l.offsetPos(tok, err.offset), End: l.offsetPos(tok, err.offset+err.length)}
+		l.error(diag.InvalidEscape, span, "%s", err.msg)
+	}
+
+	return tok
+}
+
+// scanUntil consumes the body of a string up to and including its closing
+// quote, reporting an unterminated string starting at start when the body
+// runs into the end of input, or the end of the line if multiline is false.
+// When escapes are enabled, it also stops after a placeholder's "${" and
+// reports it by returning true.
+func (l *Lexer) scanUntil(start token.Pos, quote string, multiline bool, escapes bool) bool {
+	for l.hasMoreTokens() {
+		switch ch := l.peek(); {
+		case ch == '\n' && !multiline:
+			l.error(diag.UnterminatedString, l.span(start), "Unterminated string")
+			return false
+		case ch == '\\' && escapes:
+			l.advance()
+			if l.hasMoreTokens() && (multiline || l.peek() != '\n') {
+				l.advance()
+			}
+		case ch == '$' && l.peekAt(1) == '{' && escapes:
+			l.advance()
+			l.advance()
+			return true
+		case l.lookingAt(quote):
+			for range quote {
+				l.advance()
+			}
+			return false
+		default:
+			l.advance()
+		}
+	}
+
+	l.error(diag.UnterminatedString, l.span(start), "Unterminated string")
+	return false
+}
+
+// chunkSize is how many bytes are read from an io.Reader at a time.
+const chunkSize = 4096
+
 func (l *Lexer) hasMoreTokens() bool {
-	return l.cursor < len(l.input)
+	return l.fill(1)
+}
+
+// fill makes sure n bytes past the cursor are buffered, reading more input if
+// needed. It returns false if the input ends first.
+func (l *Lexer) fill(n int) bool {
+	return l.cursor-l.base+n <= len(l.input) || l.fillSlow(n)
+}
+
+func (l *Lexer) fillSlow(n int) bool {
+	for l.reader != nil {
+		l.read()
+		if l.cursor-l.base+n <= len(l.input) {
+			return true
+		}
+	}
+	return false
+}
+
+// read appends the next chunk of the reader to the buffer. The bytes before
+// the current token are dropped once they make up half of the buffer, so that
+// a long token is not copied again for every chunk read while scanning it.
+func (l *Lexer) read() {
+	if l.chunk == nil {
+		l.chunk = make([]byte, chunkSize)
+	}
+
+	if drop := l.start.Offset - l.base; drop > 0 && drop >= len(l.input)/2 {
+		l.input = l.input[:copy(l.input, l.input[drop:])]
+		l.base += drop
+	}
+
+	n, err := l.reader.Read(l.chunk)
+	l.input = append(l.input, l.chunk[:n]...)
+
+	if err != nil {
+		if err != io.EOF {
+			l.error(diag.ReadError, l.span(l.pos()), "Could not read input: %s", err)
+		}
+		l.reader = nil
+	}
 }
 
-func (l *Lexer) match(regex string, input string) (s string, ok bool) {
-	re := regexp.MustCompile(regex)
-	matched := re.FindString(input)
+// peek returns the current byte without consuming it, or 0 at end of input.
+func (l *Lexer) peek() byte {
+	return l.peekAt(0)
+}
+
+// peekAt looks n bytes past the current one, returning 0 past end of input.
+func (l *Lexer) peekAt(n int) byte {
+	if !l.fill(n + 1) {
+		return 0
+	}
+	return l.input[l.cursor-l.base+n]
+}
+
+// peekRune decodes the current character without consuming it. It returns
+// utf8.RuneError at end of input and for invalid encodings.
+func (l *Lexer) peekRune() rune {
+	return l.peekRuneAt(0)
+}
 
-	if matched == "" {
-		return "", false
+// peekRuneAt decodes the character starting n bytes past the current one.
+func (l *Lexer) peekRuneAt(n int) rune {
+	if !l.fill(n + 1) {
+		return utf8.RuneError
 	}
+	if ch := l.input[l.cursor-l.base+n]; ch < utf8.RuneSelf {
+		return rune(ch)
+	}
+	l.fill(n + utf8.UTFMax)
+	r, _ := utf8.DecodeRune(l.input[l.cursor-l.base+n:])
+	return r
+}
+
+// advanceRune consumes every byte of the current character.
+func (l *Lexer) advanceRune() {
+	l.fill(utf8.UTFMax)
+	_, size := utf8.DecodeRune(l.input[l.cursor-l.base:])
+	for i := 0; i < size; i++ {
+		l.advance()
+	}
+}
+
+// advance consumes the current byte and keeps the line and column up to date.
+// Columns count bytes, so a multi-byte character advances the column by its
+// encoded length.
+func (l *Lexer) advance() byte {
+	ch := l.input[l.cursor-l.base]
+	l.cursor++
+
+	if ch == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
+	return ch
+}
+
+// lookingAt reports whether the unread input starts with s.
+func (l *Lexer) lookingAt(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if l.peekAt(i) != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// match consumes the current byte if it is the expected one.
+func (l *Lexer) match(expected byte) bool {
+	if !l.hasMoreTokens() || l.peek() != expected {
+		return false
+	}
+	l.advance()
+	return true
+}
+
+// either picks the two-character operator when the current byte completes it,
+// and the single-character one otherwise.
+func (l *Lexer) either(next byte, double token.TokenType, single token.TokenType) token.TokenType {
+	if l.match(next) {
+		return double
+	}
+	return single
+}
+
+// pos returns the position of the next unread byte.
+func (l *Lexer) pos() token.Pos {
+	return token.Pos{Filename: l.filename, Offset: l.cursor, Line: l.line, Column: l.column}
+}
+
+// offsetPos returns the position of the byte n bytes into tok.
+func (l *Lexer) offsetPos(tok token.Token, n int) token.Pos {
+	pos := tok.Span.Start
+	for i := 0; i < n; i++ {
+		pos.Offset++
+		if tok.Literal[i] == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
+
+// error reports a lexical error covering span.
+func (l *Lexer) error(code diag.Code, span token.Span, format string, args ...interface{}) {
+	l.diags.Report(diag.Errorf(code, span, format, args...))
+}
+
+// span returns the source range from start to the cursor.
+func (l *Lexer) span(start token.Pos) token.Span {
+	return token.Span{Start: start, End: l.pos()}
+}
+
+// text returns the bytes consumed since start. They are copied, as the
+// buffer is reused when more input is read.
+func (l *Lexer) text() string {
+	return string(l.input[l.start.Offset-l.base : l.cursor-l.base])
+}
+
+// emit builds a token of the given type from the bytes consumed since start.
+func (l *Lexer) emit(tokenType token.TokenType) token.Token {
+	return token.Token{
+		Type:    tokenType,
+		Literal: l.text(),
+		Span:    token.Span{Start: l.start, End: l.pos()},
+	}
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// isDigitOf reports whether ch is a digit in base 2, 8, 10 or 16.
+func isDigitOf(ch byte, base int) bool {
+	if base == 16 {
+		return hexValue(ch) >= 0
+	}
+	return '0' <= ch && int(ch-'0') < base
+}
+
+// prefixBase returns the base selected by the letter of a "0x", "0o" or "0b"
+// prefix, or 0 if ch does not start a prefix.
+func prefixBase(ch byte) int {
+	switch ch {
+	case 'x', 'X':
+		return 16
+	case 'o', 'O':
+		return 8
+	case 'b', 'B':
+		return 2
+	}
+	return 0
+}
 
-	l.cursor += len(matched)
-	return matched, true
+func isLetter(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
 }
 
-func (l *Lexer) newToken(tokenType token.TokenType, value string) token.Token {
-	return token.Token{Type: tokenType, Literal: string(value), Line: l.line}
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\v' || ch == '\f'
 }
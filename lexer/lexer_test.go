@@ -1,8 +1,13 @@
 package lexer
 
 import (
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/jellycat-io/gero/diag"
 	"github.com/jellycat-io/gero/token"
 )
 
@@ -57,10 +62,327 @@ func TestNextToken(t *testing.T) {
 	l := New(input)
 
 	for i, tt := range tests {
-		tok, ok := l.NextToken().(token.Token)
-		if !ok {
-			return
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d] - Wrong token type. Expected = %q, got = %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d] - Wrong token literal. Expected = %q, got = %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIdentifiersAndKeywords(t *testing.T) {
+	input := `
+		def module import let true false nil if else return
+		while for in break continue
+		foo _bar baz42 café größe
+		letter iffy index forever
+	`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FUNCTION, "def"},
+		{token.MODULE, "module"},
+		{token.IMPORT, "import"},
+		{token.LET, "let"},
+		{token.TRUE, "true"},
+		{token.FALSE, "false"},
+		{token.NIL, "nil"},
+		{token.IF, "if"},
+		{token.ELSE, "else"},
+		{token.RETURN, "return"},
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IDENT, "foo"},
+		{token.IDENT, "_bar"},
+		{token.IDENT, "baz42"},
+		{token.IDENT, "café"},
+		{token.IDENT, "größe"},
+		{token.IDENT, "letter"},
+		{token.IDENT, "iffy"},
+		{token.IDENT, "index"},
+		{token.IDENT, "forever"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d] - Wrong token type. Expected = %q, got = %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d] - Wrong token literal. Expected = %q, got = %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestOperatorsAndDelimiters(t *testing.T) {
+	input := `= == != ! < > <= >= && || -> .. . , : [ ] += -= *= /= %= - -- ===`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.ASSIGN, "="},
+		{token.EQ, "=="},
+		{token.NOT_EQ, "!="},
+		{token.BANG, "!"},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.ARROW, "->"},
+		{token.DOT_DOT, ".."},
+		{token.DOT, "."},
+		{token.COMMA, ","},
+		{token.COLON, ":"},
+		{token.LBRACKET, "["},
+		{token.RBRACKET, "]"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS_ASSIGN, "-="},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.MINUS, "-"},
+		{token.MINUS, "-"},
+		{token.MINUS, "-"},
+		{token.EQ, "=="},
+		{token.ASSIGN, "="},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d] - Wrong token type. Expected = %q, got = %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d] - Wrong token literal. Expected = %q, got = %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := `0 42 0xFF 0o755 0b1010 1_000_000 0xff_ff 3.14 .5 5. 6.02e23 1E-9 2e+3 5.e3 5.E-3 1_0.2_5 1..5 5.abs 0755`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0"},
+		{token.INT, "42"},
+		{token.INT, "0xFF"},
+		{token.INT, "0o755"},
+		{token.INT, "0b1010"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0xff_ff"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, ".5"},
+		{token.FLOAT, "5."},
+		{token.FLOAT, "6.02e23"},
+		{token.FLOAT, "1E-9"},
+		{token.FLOAT, "2e+3"},
+		{token.FLOAT, "5.e3"},
+		{token.FLOAT, "5.E-3"},
+		{token.FLOAT, "1_0.2_5"},
+		{token.INT, "1"},
+		{token.DOT_DOT, ".."},
+		{token.INT, "5"},
+		{token.INT, "5"},
+		{token.DOT, "."},
+		{token.IDENT, "abs"},
+		{token.INT, "0755"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d] - Wrong token type. Expected = %q, got = %q", i, tt.expectedType, tok.Type)
 		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d] - Wrong token literal. Expected = %q, got = %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Fatalf("Unexpected errors: %q", l.Errors())
+	}
+}
+
+func TestNumberLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedType  token.TokenType
+		expectedError string
+	}{
+		{"0x", token.ILLEGAL, `Missing digits after "0x" at 1:1`},
+		{"0b102", token.ILLEGAL, `Invalid number literal "0b102" at 1:1`},
+		{"12abc", token.ILLEGAL, `Invalid number literal "12abc" at 1:1`},
+		{"1e", token.ILLEGAL, `Invalid number literal "1e" at 1:1`},
+		{"1__0", token.ILLEGAL, "'_' must separate successive digits at 1:2"},
+		{"10_", token.ILLEGAL, "'_' must separate successive digits at 1:3"},
+		{"1_.5", token.ILLEGAL, "'_' must separate successive digits at 1:2"},
+		{"0x_FF", token.ILLEGAL, "'_' must separate successive digits at 1:3"},
+		{"0x_", token.ILLEGAL, "'_' must separate successive digits at 1:3"},
+		{"1_abc", token.ILLEGAL, "'_' must separate successive digits at 1:2"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d] - Wrong token type. Expected = %q, got = %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.input {
+			t.Fatalf("Tests[%d] - Wrong token literal. Expected = %q, got = %q", i, tt.input, tok.Literal)
+		}
+		if len(l.Errors()) != 1 || l.Errors()[0].String() != tt.expectedError {
+			t.Fatalf("Tests[%d] - Wrong errors. Expected = %q, got = %q", i, tt.expectedError, l.Errors())
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue string
+	}{
+		{`"hello"`, "hello"},
+		{`'hello'`, "hello"},
+		{`""`, ""},
+		{`"tab\tnew\nline\rcr\\slash"`, "tab\tnew\nline\rcr\\slash"},
+		{`"say \"hi\" and \'bye\'"`, `say "hi" and 'bye'`},
+		{`'it\'s'`, "it's"},
+		{`"nul\0"`, "nul\x00"},
+		{`"\u{48}\u{e9}\u{1F600}"`, "Hé😀"},
+		{"`raw \\n \"quoted\"`", `raw \n "quoted"`},
+		{"`multi\nline`", "multi\nline"},
+		{`"""one
+"two"
+three"""`, "one\n\"two\"\nthree"},
+		{`"""tab\t"""`, "tab\t"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("Tests[%d] - Wrong token type. Expected = %q, got = %q", i, token.STRING, tok.Type)
+		}
+		if tok.Literal != tt.input {
+			t.Fatalf("Tests[%d] - Wrong token literal. Expected = %q, got = %q", i, tt.input, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Fatalf("Tests[%d] - Unexpected errors: %q", i, l.Errors())
+		}
+
+		value, err := Unquote(tok.Literal)
+		if err != nil {
+			t.Fatalf("Tests[%d] - Unquote failed: %s", i, err)
+		}
+		if value != tt.expectedValue {
+			t.Fatalf("Tests[%d] - Wrong value. Expected = %q, got = %q", i, tt.expectedValue, value)
+		}
+	}
+}
+
+func TestStringLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{`"bad \q escape"`, []string{`Invalid escape sequence "\q" at 1:6`}},
+		{`"a\u{110000}" "\u{zz}"`, []string{
+			`Invalid Unicode code point "\u{110000}" at 1:3`,
+			`Invalid Unicode escape "\u{zz}", expected 1 to 6 hex digits at 1:16`,
+		}},
+		{`"\u41"`, []string{`Invalid Unicode escape, expected "\u{...}" at 1:2`}},
+		{"\"open\n42", []string{"Unterminated string at 1:1"}},
+		{"x `open\n\n", []string{"Unterminated string at 1:3"}},
+		{"\n  \"\"\"open\n\\q", []string{
+			"Unterminated string at 2:3",
+			`Invalid escape sequence "\q" at 3:1`,
+		}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for l.NextToken().Type != token.EOF {
+		}
+
+		if len(l.Errors()) != len(tt.expectedErrors) {
+			t.Fatalf("Tests[%d] - Wrong number of errors. Expected = %d, got = %d (%q)", i, len(tt.expectedErrors), len(l.Errors()), l.Errors())
+		}
+		for j, msg := range tt.expectedErrors {
+			if l.Errors()[j].String() != msg {
+				t.Errorf("Tests[%d] - Errors[%d] - Expected = %q, got = %q", i, j, msg, l.Errors()[j])
+			}
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"hello ${name}, you have ${count + 1} items"
+		"a ${ {} } b"
+		"out ${ "in ${deep}" + 'x' } end"
+		"\${literal}"
+		"""multi ${x}
+line"""`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_HEAD, `"hello ${`},
+		{token.IDENT, "name"},
+		{token.STRING_MIDDLE, "}, you have ${"},
+		{token.IDENT, "count"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.STRING_TAIL, `} items"`},
+		{token.STRING_HEAD, `"a ${`},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.STRING_TAIL, `} b"`},
+		{token.STRING_HEAD, `"out ${`},
+		{token.STRING_HEAD, `"in ${`},
+		{token.IDENT, "deep"},
+		{token.STRING_TAIL, `}"`},
+		{token.PLUS, "+"},
+		{token.STRING, `'x'`},
+		{token.STRING_TAIL, `} end"`},
+		{token.STRING, `"\${literal}"`},
+		{token.STRING_HEAD, `"""multi ${`},
+		{token.IDENT, "x"},
+		{token.STRING_TAIL, "}\nline\"\"\""},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
 
 		if tok.Type != tt.expectedType {
 			t.Fatalf("Tests[%d] - Wrong token type. Expected = %q, got = %q", i, tt.expectedType, tok.Type)
@@ -69,4 +391,354 @@ func TestNextToken(t *testing.T) {
 			t.Fatalf("Tests[%d] - Wrong token literal. Expected = %q, got = %q", i, tt.expectedLiteral, tok.Literal)
 		}
 	}
+
+	if len(l.Errors()) != 0 {
+		t.Fatalf("Unexpected errors: %q", l.Errors())
+	}
+}
+
+func TestUnterminatedInterpolation(t *testing.T) {
+	input := `"a ${x
+		`
+
+	l := New(input)
+	for l.NextToken().Type != token.EOF {
+	}
+
+	if len(l.Errors()) != 1 || l.Errors()[0].String() != "Unterminated string at 1:1" {
+		t.Fatalf("Wrong errors. got = %q", l.Errors())
+	}
+}
+
+func TestIllegalCharacters(t *testing.T) {
+	input := "1 @ 2 & 3 € 4 \"open"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "1"},
+		{token.ILLEGAL, "@"},
+		{token.INT, "2"},
+		{token.ILLEGAL, "&"},
+		{token.INT, "3"},
+		{token.ILLEGAL, "€"},
+		{token.INT, "4"},
+		{token.STRING, `"open`},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d] - Wrong token type. Expected = %q, got = %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d] - Wrong token literal. Expected = %q, got = %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	expectedErrors := []string{
+		"Unexpected character '@' at 1:3",
+		"Unexpected character '&' at 1:7",
+		"Unexpected character '€' at 1:11",
+		"Unterminated string at 1:17",
+	}
+
+	if len(l.Errors()) != len(expectedErrors) {
+		t.Fatalf("Wrong number of errors. Expected = %d, got = %d (%q)", len(expectedErrors), len(l.Errors()), l.Errors())
+	}
+	for i, msg := range expectedErrors {
+		if l.Errors()[i].String() != msg {
+			t.Errorf("Errors[%d] - Expected = %q, got = %q", i, msg, l.Errors()[i])
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "42;\n  \"\"\"two\nlines\"\"\" + 3.5"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedSpan    token.Span
+	}{
+		{"42", span(0, 1, 1, 2, 1, 3)},
+		{";", span(2, 1, 3, 3, 1, 4)},
+		{"\"\"\"two\nlines\"\"\"", span(6, 2, 3, 21, 3, 9)},
+		{"+", span(22, 3, 10, 23, 3, 11)},
+		{"3.5", span(24, 3, 12, 27, 3, 15)},
+		{"", span(27, 3, 15, 27, 3, 15)},
+	}
+
+	l := NewFile("test.gero", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d] - Wrong token literal. Expected = %q, got = %q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Span != tt.expectedSpan {
+			t.Fatalf("Tests[%d] - Wrong token span. Expected = %+v, got = %+v", i, tt.expectedSpan, tok.Span)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	input := strings.Repeat(`
+		// A comment that spans a chunk boundary
+		let größe = 0xFF + 6.02e23 * (1 - .5);
+		"escape \u{1F600} and ${"nested ${deep}"} interpolation";
+		"""multi
+		line""" /* block
+		comment */ `+"`raw`"+` ; € 12abc
+	`, 200)
+
+	expected := collectTokens(input)
+
+	readers := map[string]func(io.Reader) io.Reader{
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+		"data err": iotest.DataErrReader,
+	}
+
+	for name, wrap := range readers {
+		l := NewReader("", wrap(strings.NewReader(input)))
+
+		for i, tt := range expected {
+			tok := l.NextToken()
+			if !reflect.DeepEqual(tok, tt) {
+				t.Fatalf("%s - Tokens[%d] - Expected = %+v, got = %+v", name, i, tt, tok)
+			}
+		}
+
+		if len(l.Errors()) != len(New(input).drain().Errors()) {
+			t.Fatalf("%s - Wrong number of errors. got = %q", name, l.Errors())
+		}
+	}
+}
+
+func TestErrorDiagnostics(t *testing.T) {
+	input := "\"a\\qb\" 1__0 0x @ /* open"
+
+	tests := []struct {
+		code  diag.Code
+		start string
+		end   string
+	}{
+		{diag.InvalidEscape, "1:3", "1:5"},
+		{diag.InvalidNumber, "1:9", "1:10"},
+		{diag.InvalidNumber, "1:13", "1:15"},
+		{diag.UnexpectedCharacter, "1:16", "1:17"},
+		{diag.UnterminatedComment, "1:18", "1:25"},
+	}
+
+	l := New(input).drain()
+
+	errors := l.Errors()
+	if len(errors) != len(tests) {
+		t.Fatalf("Wrong number of errors. Expected = %d, got = %d (%q)", len(tests), len(errors), errors)
+	}
+
+	for i, tt := range tests {
+		d := errors[i]
+		if d.Code != tt.code {
+			t.Errorf("Tests[%d] - Wrong code. Expected = %s, got = %s (%q)", i, tt.code, d.Code, d)
+		}
+		if d.Span.Start.String() != tt.start || d.Span.End.String() != tt.end {
+			t.Errorf("Tests[%d] - Wrong span. Expected = %s-%s, got = %s-%s", i, tt.start, tt.end, d.Span.Start, d.Span.End)
+		}
+	}
+}
+
+func TestNewReaderReportsReadErrors(t *testing.T) {
+	l := NewReader("broken.gero", iotest.TimeoutReader(strings.NewReader("1 + 2;")))
+	l.drain()
+
+	if len(l.Errors()) != 1 || l.Errors()[0].String() != "Could not read input: timeout at broken.gero:1:7" {
+		t.Fatalf("Wrong errors. got = %q", l.Errors())
+	}
+}
+
+func TestTokens(t *testing.T) {
+	l := New(`1 + 2;`)
+
+	var literals []string
+	for it := l.Tokens(); it.Next(); {
+		literals = append(literals, it.Token().Literal)
+	}
+
+	if strings.Join(literals, " ") != "1 + 2 ;" {
+		t.Fatalf("Wrong tokens. got = %q", literals)
+	}
+}
+
+func TestScanTrivia(t *testing.T) {
+	input := "// header\r\n\n" +
+		"let x = 1; // trailing\n" +
+		"\t/* block\n comment */ \"a ${ b } c\" @\n" +
+		"  `raw` /* unterminated"
+
+	for _, l := range []*Lexer{New(input), NewReader("", iotest.OneByteReader(strings.NewReader(input)))} {
+		l.SetMode(ScanTrivia)
+
+		var out strings.Builder
+		var tokens []token.Token
+		for {
+			tok := l.NextToken()
+			tokens = append(tokens, tok)
+
+			for _, trivia := range tok.LeadingTrivia {
+				out.WriteString(trivia.Literal)
+			}
+			out.WriteString(tok.Literal)
+			for _, trivia := range tok.TrailingTrivia {
+				out.WriteString(trivia.Literal)
+			}
+
+			if tok.Type == token.EOF {
+				break
+			}
+		}
+
+		if out.String() != input {
+			t.Fatalf("Source not rebuilt. Expected = %q, got = %q", input, out.String())
+		}
+
+		let := tokens[0]
+		expectedLeading := []token.Trivia{
+			{Type: token.COMMENT, Literal: "// header", Span: token.Span{
+				Start: token.Pos{Offset: 0, Line: 1, Column: 1},
+				End:   token.Pos{Offset: 9, Line: 1, Column: 10},
+			}},
+			{Type: token.NEWLINE, Literal: "\r\n", Span: token.Span{
+				Start: token.Pos{Offset: 9, Line: 1, Column: 10},
+				End:   token.Pos{Offset: 11, Line: 2, Column: 1},
+			}},
+			{Type: token.NEWLINE, Literal: "\n", Span: token.Span{
+				Start: token.Pos{Offset: 11, Line: 2, Column: 1},
+				End:   token.Pos{Offset: 12, Line: 3, Column: 1},
+			}},
+		}
+		if !reflect.DeepEqual(let.LeadingTrivia, expectedLeading) {
+			t.Fatalf("Wrong leading trivia. Expected = %+v, got = %+v", expectedLeading, let.LeadingTrivia)
+		}
+
+		semi := tokens[4]
+		if len(semi.TrailingTrivia) != 3 || semi.TrailingTrivia[1].Literal != "// trailing" || semi.TrailingTrivia[2].Type != token.NEWLINE {
+			t.Fatalf("Wrong trailing trivia for %q. got = %+v", semi.Literal, semi.TrailingTrivia)
+		}
+
+		raw := tokens[len(tokens)-2]
+		if len(raw.TrailingTrivia) != 2 || raw.TrailingTrivia[1].Literal != "/* unterminated" {
+			t.Fatalf("Wrong trailing trivia for %q. got = %+v", raw.Literal, raw.TrailingTrivia)
+		}
+	}
+}
+
+func TestTriviaIsSkippedByDefault(t *testing.T) {
+	l := New("  // comment\n1 /* c */\n")
+
+	for _, tok := range collectTokensFrom(l) {
+		if tok.LeadingTrivia != nil || tok.TrailingTrivia != nil {
+			t.Fatalf("Unexpected trivia on %q: %+v %+v", tok.Literal, tok.LeadingTrivia, tok.TrailingTrivia)
+		}
+	}
+}
+
+func TestNextTokenIsDeterministic(t *testing.T) {
+	input := `3.14; .5; 42; "hello"; (1 + 2) * 3 / 4 % 5 - 6;`
+
+	first := collectTokens(input)
+	for run := 0; run < 100; run++ {
+		tokens := collectTokens(input)
+
+		if len(tokens) != len(first) {
+			t.Fatalf("Run %d - Wrong token count. Expected = %d, got = %d", run, len(first), len(tokens))
+		}
+		for i := range tokens {
+			if !reflect.DeepEqual(tokens[i], first[i]) {
+				t.Fatalf("Run %d, token %d - Expected = %+v, got = %+v", run, i, first[i], tokens[i])
+			}
+		}
+	}
+
+	if first[0].Type != token.FLOAT || first[2].Type != token.FLOAT {
+		t.Fatalf("Floats lexed as %q and %q", first[0].Type, first[2].Type)
+	}
+}
+
+func BenchmarkNextToken(b *testing.B) {
+	chunk := `
+		// Arithmetic
+		(12 + 3.5) * 42 / 7 % 3 - .25;
+		/* block comment */
+		{ "hello world"; 'single'; }
+	`
+	input := strings.Repeat(chunk, (4<<20)/len(chunk))
+
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := New(input)
+		for {
+			tok := l.NextToken()
+			if tok.Type == token.EOF {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkNewReader(b *testing.B) {
+	chunk := `
+		// Arithmetic
+		(12 + 3.5) * 42 / 7 % 3 - .25;
+		/* block comment */
+		{ "hello world"; 'single'; }
+	`
+	input := strings.Repeat(chunk, (4<<20)/len(chunk))
+
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := NewReader("", strings.NewReader(input))
+		for l.NextToken().Type != token.EOF {
+		}
+	}
+}
+
+// drain scans the remaining tokens, leaving only the lexer's errors behind.
+func (l *Lexer) drain() *Lexer {
+	for l.NextToken().Type != token.EOF {
+	}
+	return l
+}
+
+func collectTokens(input string) []token.Token {
+	return collectTokensFrom(New(input))
+}
+
+func collectTokensFrom(l *Lexer) []token.Token {
+	var tokens []token.Token
+
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+func span(startOffset, startLine, startColumn, endOffset, endLine, endColumn int) token.Span {
+	return token.Span{
+		Start: token.Pos{Filename: "test.gero", Offset: startOffset, Line: startLine, Column: startColumn},
+		End:   token.Pos{Filename: "test.gero", Offset: endOffset, Line: endLine, Column: endColumn},
+	}
 }
@@ -0,0 +1,41 @@
+package lexer
+
+import "github.com/jellycat-io/gero/token"
+
+// TokenIterator walks the tokens of a lexer up to, but not including, EOF.
+//
+//	it := l.Tokens()
+//	for it.Next() {
+//		tok := it.Token()
+//		...
+//	}
+type TokenIterator struct {
+	l    *Lexer
+	tok  token.Token
+	done bool
+}
+
+// Tokens returns an iterator over the remaining tokens of the lexer.
+func (l *Lexer) Tokens() *TokenIterator {
+	return &TokenIterator{l: l}
+}
+
+// Next scans the next token and reports whether there was one before EOF.
+func (it *TokenIterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	it.tok = it.l.NextToken()
+	if it.tok.Type == token.EOF {
+		it.done = true
+		return false
+	}
+
+	return true
+}
+
+// Token returns the token scanned by the last call to Next.
+func (it *TokenIterator) Token() token.Token {
+	return it.tok
+}
@@ -0,0 +1,191 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jellycat-io/gero/token"
+)
+
+// Unquote returns the value of a string literal as scanned by the lexer. The
+// quotes are removed and, except in raw strings, escape sequences are decoded.
+// The error reports the first invalid escape sequence; the lexer has already
+// reported all of them with their positions.
+func Unquote(lit string) (string, error) {
+	value, errs := unquote(lit)
+	if len(errs) > 0 {
+		return value, errors.New(errs[0].msg)
+	}
+	return value, nil
+}
+
+// UnquoteSegment returns the text of a STRING_HEAD, STRING_MIDDLE or
+// STRING_TAIL token with its escape sequences decoded. The quote is the
+// delimiter of the interpolated string, as returned by Delimiter for its head.
+func UnquoteSegment(tok token.Token, quote string) (string, error) {
+	value, errs := unquoteSegment(tok, quote)
+	if len(errs) > 0 {
+		return value, errors.New(errs[0].msg)
+	}
+	return value, nil
+}
+
+// Delimiter returns the opening quote of a string literal or of the head of
+// an interpolated string.
+func Delimiter(lit string) string {
+	if strings.HasPrefix(lit, `"""`) {
+		return `"""`
+	}
+	return lit[:1]
+}
+
+// escapeError is an invalid escape sequence of length bytes found offset
+// bytes into a literal.
+type escapeError struct {
+	offset int
+	length int
+	msg    string
+}
+
+func unquote(lit string) (string, []escapeError) {
+	quote := Delimiter(lit)
+
+	body := lit[len(quote):]
+	if len(body) >= len(quote) && strings.HasSuffix(body, quote) {
+		body = body[:len(body)-len(quote)]
+	}
+
+	if quote == "`" {
+		return body, nil
+	}
+
+	return unescape(body, len(quote))
+}
+
+// unquoteSegment strips the quote or '}' opening a segment and the "${" or
+// quote closing it, then decodes its escape sequences.
+func unquoteSegment(tok token.Token, quote string) (string, []escapeError) {
+	open := "}"
+	if tok.Type == token.STRING_HEAD {
+		open = quote
+	}
+
+	body := tok.Literal[len(open):]
+	if tok.Type == token.STRING_TAIL {
+		if len(body) >= len(quote) && strings.HasSuffix(body, quote) {
+			body = body[:len(body)-len(quote)]
+		}
+	} else {
+		body = strings.TrimSuffix(body, "${")
+	}
+
+	return unescape(body, len(open))
+}
+
+// unescape decodes the escape sequences in s, which starts offset bytes into
+// its literal.
+func unescape(s string, offset int) (string, []escapeError) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+
+	var out strings.Builder
+	var errs []escapeError
+
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			out.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		r, n, msg := decodeEscape(s[i:])
+		if msg != "" {
+			errs = append(errs, escapeError{offset: offset + i, length: n, msg: msg})
+		} else {
+			out.WriteRune(r)
+		}
+		i += n
+	}
+
+	return out.String(), errs
+}
+
+// decodeEscape decodes the escape sequence at the start of s. It returns the
+// decoded character, the length of the sequence and, for an invalid sequence,
+// an error message.
+func decodeEscape(s string) (rune, int, string) {
+	if len(s) < 2 {
+		return 0, len(s), `Invalid escape sequence "\"`
+	}
+
+	switch s[1] {
+	case 'n':
+		return '\n', 2, ""
+	case 't':
+		return '\t', 2, ""
+	case 'r':
+		return '\r', 2, ""
+	case '0':
+		return 0, 2, ""
+	case '\\', '"', '\'', '`', '$':
+		return rune(s[1]), 2, ""
+	case 'u':
+		return decodeUnicodeEscape(s)
+	}
+
+	_, size := utf8.DecodeRuneInString(s[1:])
+	return 0, 1 + size, fmt.Sprintf("Invalid escape sequence \"%s\"", s[:1+size])
+}
+
+// decodeUnicodeEscape decodes a "\u{XXXX}" sequence of one to six hex digits.
+func decodeUnicodeEscape(s string) (rune, int, string) {
+	if len(s) < 3 || s[2] != '{' {
+		return 0, 2, `Invalid Unicode escape, expected "\u{...}"`
+	}
+
+	// Only look as far as the longest valid sequence, "\u{10FFFF}".
+	window := s
+	if len(window) > 10 {
+		window = window[:10]
+	}
+
+	end := strings.IndexByte(window, '}')
+	if end < 0 {
+		return 0, 3, `Unterminated Unicode escape, expected "}"`
+	}
+
+	digits := s[3:end]
+	if len(digits) == 0 || len(digits) > 6 {
+		return 0, end + 1, fmt.Sprintf("Invalid Unicode escape \"%s\", expected 1 to 6 hex digits", s[:end+1])
+	}
+
+	var r rune
+	for i := 0; i < len(digits); i++ {
+		d := hexValue(digits[i])
+		if d < 0 {
+			return 0, end + 1, fmt.Sprintf("Invalid Unicode escape \"%s\", expected 1 to 6 hex digits", s[:end+1])
+		}
+		r = r*16 + rune(d)
+	}
+
+	if !utf8.ValidRune(r) {
+		return 0, end + 1, fmt.Sprintf("Invalid Unicode code point \"%s\"", s[:end+1])
+	}
+
+	return r, end + 1, ""
+}
+
+func hexValue(ch byte) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	case 'A' <= ch && ch <= 'F':
+		return int(ch-'A') + 10
+	}
+	return -1
+}
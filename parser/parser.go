@@ -5,10 +5,13 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/jellycat-io/gero/ast"
+	"github.com/jellycat-io/gero/diag"
 	"github.com/jellycat-io/gero/lexer"
 	"github.com/jellycat-io/gero/token"
 )
@@ -16,30 +19,49 @@ import (
 type Parser struct {
 	l         *lexer.Lexer
 	peekToken token.Token
-	errors    []string
-}
+	prevEnd   token.Pos       // end of the last token consumed
+	diags     *diag.Collector // shared with the lexer
 
-func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l}
+	// ahead holds the tokens already read past peekToken, for the few places
+	// that need more than one token of lookahead.
+	ahead []token.Token
 
-	tok, ok := p.l.NextToken().(token.Token)
-	if !ok {
-		return nil
-	}
+	// panicking is set by a syntax error until the parser has skipped to the
+	// start of the next statement. Syntax errors reported meanwhile are
+	// dropped, since they are most likely caused by the first one.
+	panicking bool
+
+	// loopDepth counts the loops enclosing the current statement within the
+	// current function, to reject a break or continue outside of a loop.
+	loopDepth int
+
+	// valueBlock is set while parsing the statements directly inside a branch
+	// of an if, whose last expression statement gives its value.
+	valueBlock bool
+}
 
-	p.peekToken = tok
+func New(l *lexer.Lexer) *Parser {
+	p := &Parser{l: l, diags: l.Diagnostics()}
+	p.nextToken()
 	return p
 }
 
-func (p *Parser) Errors() []string {
-	return p.errors
+// Errors returns the lexical and syntax errors found so far, in the order
+// they were reported.
+func (p *Parser) Errors() []diag.Diagnostic {
+	return p.diags.Errors()
+}
+
+// Diagnostics returns the collector the lexer and the parser report into.
+func (p *Parser) Diagnostics() *diag.Collector {
+	return p.diags
 }
 
 /**
  * Main entry point.
  *
  * Program
- * 	: StatementList
+ * 	: OptStatementList
  * 	;
  */
 func (p *Parser) Program() *ast.Program {
@@ -47,205 +69,1160 @@ func (p *Parser) Program() *ast.Program {
 }
 
 /**
- * StatementList
- * 	: Statement
- * 	| StatementList Statement -> Statement*
+ * OptStatementList
+ * 	: ε
+ * 	| OptStatementList Statement -> Statement*
  * 	;
+ *
+ * The list ends at the stop token, which is left for the caller. An empty
+ * source, or one with only whitespace and comments, is an empty list.
  */
 func (p *Parser) StatementList(stopTokenType token.TokenType) []ast.Statement {
-	statementList := []ast.Statement{p.Statement()}
+	statementList := []ast.Statement{}
 
-	for !p.match(stopTokenType) {
-		statementList = append(statementList, p.Statement())
+	for !p.match(stopTokenType) && !p.isAtEnd() {
+		statementList = append(statementList, p.statement())
 	}
 
 	return statementList
 }
 
+// statement parses a statement of a statement list and recovers from the
+// syntax errors found in it, if any.
+func (p *Parser) statement() ast.Statement {
+	stmt := p.Statement()
+	if p.panicking {
+		p.synchronize()
+	}
+	return stmt
+}
+
 /**
  * Statement
  * 	: ExpressionStatement
  * 	| BlockStatement
+ * 	| VariableStatement
+ * 	| FunctionDeclaration
+ * 	| ReturnStatement
+ * 	| IfStatement
+ * 	| WhileStatement
+ * 	| ForStatement
+ * 	| BreakStatement
+ * 	| ContinueStatement
+ * 	| EmptyStatement
  * 	;
  */
 func (p *Parser) Statement() ast.Statement {
 	switch p.peekToken.Type {
+	case token.SEMI:
+		return p.EmptyStatement()
 	case token.LBRACE:
+		if p.isMapLiteral() {
+			return p.ExpressionStatement()
+		}
 		return p.BlockStatement()
+	case token.LET:
+		return p.VariableStatement()
+	case token.FUNCTION:
+		if p.lookAhead(1).Type == token.LPAREN {
+			return p.ExpressionStatement()
+		}
+		return p.FunctionDeclaration()
+	case token.RETURN:
+		return p.ReturnStatement()
+	case token.IF:
+		return p.IfStatement()
+	case token.WHILE:
+		return p.WhileStatement()
+	case token.FOR:
+		return p.ForStatement()
+	case token.BREAK:
+		return p.BreakStatement()
+	case token.CONTINUE:
+		return p.ContinueStatement()
 	default:
+		if !p.startsExpression() {
+			return p.BadStatement()
+		}
 		return p.ExpressionStatement()
 	}
 }
 
+// BadStatement reports a token that cannot start a statement and skips it,
+// along with the tokens after it up to one that can, or past a ';'. Panic
+// mode is left as it was found, so an earlier error still gets its recovery.
+func (p *Parser) BadStatement() *ast.BadStatement {
+	start := p.peekToken
+	panicking := p.panicking
+
+	p.syntaxError(diag.Errorf(diag.UnexpectedToken, start.Span, "Unexpected token %q", start.Type))
+	p.nextToken()
+
+	for !p.isAtEnd() && !p.match(token.RBRACE) && !p.startsStatement() {
+		if p.match(token.SEMI) {
+			p.nextToken()
+			break
+		}
+		p.nextToken()
+	}
+	p.panicking = panicking
+
+	return ast.NewBadStatement(start.Span.Start, p.peekToken.Span.Start)
+}
+
+// startsStatement tells whether peekToken can start a statement.
+func (p *Parser) startsStatement() bool {
+	switch p.peekToken.Type {
+	case token.LET, token.FUNCTION, token.RETURN, token.IF, token.WHILE,
+		token.FOR, token.BREAK, token.CONTINUE:
+		return true
+	}
+	return p.startsExpression()
+}
+
+// startsExpression tells whether peekToken can start an expression.
+func (p *Parser) startsExpression() bool {
+	switch p.peekToken.Type {
+	case token.INT, token.FLOAT, token.STRING, token.STRING_HEAD,
+		token.TRUE, token.FALSE, token.NIL, token.IDENT,
+		token.LPAREN, token.LBRACKET, token.LBRACE, token.FUNCTION, token.IF,
+		token.MINUS, token.PLUS, token.BANG:
+		return true
+	}
+	return false
+}
+
+// synchronize skips the tokens left over from a statement with a syntax error,
+// past the next ';' or up to a '{', a '}' or a keyword that starts a
+// statement, and leaves panic mode.
+func (p *Parser) synchronize() {
+	defer func() { p.panicking = false }()
+
+	for !p.isAtEnd() {
+		switch p.peekToken.Type {
+		case token.SEMI:
+			p.nextToken()
+			return
+		case token.LBRACE, token.RBRACE, token.LET, token.FUNCTION, token.RETURN,
+			token.IF, token.WHILE, token.FOR, token.BREAK, token.CONTINUE:
+			return
+		}
+		p.nextToken()
+	}
+}
+
+// isMapLiteral tells whether the '{' opening a statement starts a map literal
+// rather than a block, by looking for a 'key:' after it. An empty '{}' is a
+// block.
+func (p *Parser) isMapLiteral() bool {
+	switch p.lookAhead(1).Type {
+	case token.IDENT, token.STRING:
+		return p.lookAhead(2).Type == token.COLON
+	}
+	return false
+}
+
+/**
+ * EmptyStatement
+ * 	: ';'
+ * 	;
+ *
+ * Lets a block statement be followed by a ';', as in def f() {};
+ */
+func (p *Parser) EmptyStatement() *ast.EmptyStatement {
+	return ast.NewEmptyStatement(p.eat(token.SEMI))
+}
+
 /**
  * BlockStatement
  * 	: '{' OptStatementList '}'
  * 	;
  */
 func (p *Parser) BlockStatement() *ast.BlockStatement {
-	var body []ast.Statement
-	p.eat(token.LBRACE)
+	return p.block(false)
+}
 
-	if p.peekToken.Type != token.RBRACE {
-		body = p.StatementList(token.RBRACE)
-	} else {
-		body = []ast.Statement{}
+// block parses a block statement. In the block of a branch of an if, value
+// is set and the ';' of the last statement may be left out when it is an
+// expression statement, as in if (a) { 1 } else { 2 }.
+func (p *Parser) block(value bool) *ast.BlockStatement {
+	valueBlock := p.valueBlock
+	p.valueBlock = value
+	defer func() { p.valueBlock = valueBlock }()
+
+	if !p.match(token.LBRACE) {
+		// Without its '{', whatever follows is not the block's body. Leave it
+		// for recovery rather than parse the rest of the file as the block.
+		p.eat(token.LBRACE)
+		at := token.Token{Span: token.Span{Start: p.peekToken.Span.Start, End: p.peekToken.Span.Start}}
+		return ast.NewBlockStatement(at, []ast.Statement{}, at)
+	}
+
+	start := p.eat(token.LBRACE)
+	body := p.StatementList(token.RBRACE)
+	end := p.eat(token.RBRACE)
+
+	return ast.NewBlockStatement(start, body, end)
+}
+
+/**
+ * VariableStatement
+ * 	: 'let' VariableDeclarationList ';'
+ * 	;
+ *
+ * VariableDeclarationList
+ * 	: VariableDeclaration
+ * 	| VariableDeclarationList ',' VariableDeclaration
+ * 	;
+ */
+func (p *Parser) VariableStatement() *ast.VariableDeclaration {
+	start := p.eat(token.LET)
+
+	declarations := []*ast.VariableDeclarator{p.VariableDeclaration()}
+	for p.match(token.COMMA) {
+		p.eat(token.COMMA)
+		declarations = append(declarations, p.VariableDeclaration())
+	}
+
+	end := p.endStatement()
+
+	return ast.NewVariableDeclaration(start, declarations, end)
+}
+
+/**
+ * VariableDeclaration
+ * 	: Identifier OptVariableInitializer
+ * 	;
+ *
+ * VariableInitializer
+ * 	: '=' Expression
+ * 	;
+ */
+func (p *Parser) VariableDeclaration() *ast.VariableDeclarator {
+	id := p.Identifier()
+
+	var init ast.Expression
+	if p.match(token.ASSIGN) {
+		p.eat(token.ASSIGN)
+		init = p.Expression()
+	}
+
+	return ast.NewVariableDeclarator(id, init)
+}
+
+/**
+ * FunctionDeclaration
+ * 	: 'def' Identifier FormalParameterList BlockStatement
+ * 	;
+ */
+func (p *Parser) FunctionDeclaration() *ast.FunctionDeclaration {
+	start := p.eat(token.FUNCTION)
+	name := p.Identifier()
+	params := p.FormalParameterList()
+	body := p.functionBody()
+
+	return ast.NewFunctionDeclaration(start, name, params, body)
+}
+
+// functionBody parses the block of a function. Loops around the function do
+// not extend into its body.
+func (p *Parser) functionBody() *ast.BlockStatement {
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
+	return p.BlockStatement()
+}
+
+/**
+ * FormalParameterList
+ * 	: '(' ')'
+ * 	| '(' Identifier (',' Identifier)* ','? ')'
+ * 	;
+ */
+func (p *Parser) FormalParameterList() []*ast.Identifier {
+	params := []*ast.Identifier{}
+
+	open := p.eat(token.LPAREN)
+	for !p.match(token.RPAREN) && !p.isAtEnd() {
+		params = append(params, p.Identifier())
+		if !p.match(token.COMMA) {
+			break
+		}
+		p.eat(token.COMMA)
+	}
+	p.closeDelimiter(open, token.RPAREN)
+
+	return params
+}
+
+/**
+ * ReturnStatement
+ * 	: 'return' OptExpression ';'
+ * 	;
+ */
+func (p *Parser) ReturnStatement() *ast.ReturnStatement {
+	start := p.eat(token.RETURN)
+
+	var argument ast.Expression
+	if !p.match(token.SEMI) {
+		argument = p.Expression()
+	}
+
+	end := p.endStatement()
+
+	return ast.NewReturnStatement(start, argument, end)
+}
+
+/**
+ * IfStatement
+ * 	: 'if' '(' Expression ')' BlockStatement
+ * 	| 'if' '(' Expression ')' BlockStatement 'else' BlockStatement
+ * 	| 'if' '(' Expression ')' BlockStatement 'else' IfStatement
+ * 	;
+ */
+func (p *Parser) IfStatement() *ast.IfStatement {
+	return p.conditional(func(start token.Token, test ast.Expression, consequent *ast.BlockStatement, alternate ast.Node) ast.Node {
+		alt, _ := alternate.(ast.Statement)
+		return ast.NewIfStatement(start, test, consequent, alt)
+	}).(*ast.IfStatement)
+}
+
+// conditional parses an if/else chain for IfStatement and IfExpression, and
+// wraps each 'if' of the chain in the node built by node. Either way the
+// branches may give a value, so the ';' after the last expression statement
+// of a branch may be left out.
+func (p *Parser) conditional(node func(start token.Token, test ast.Expression, consequent *ast.BlockStatement, alternate ast.Node) ast.Node) ast.Node {
+	start := p.eat(token.IF)
+	test := p.condition()
+	consequent := p.block(true)
+
+	var alternate ast.Node
+	if p.match(token.ELSE) {
+		p.eat(token.ELSE)
+		if p.match(token.IF) {
+			alternate = p.conditional(node)
+		} else {
+			alternate = p.block(true)
+		}
+	}
+
+	return node(start, test, consequent, alternate)
+}
+
+/**
+ * WhileStatement
+ * 	: 'while' '(' Expression ')' BlockStatement
+ * 	;
+ */
+func (p *Parser) WhileStatement() *ast.WhileStatement {
+	start := p.eat(token.WHILE)
+	test := p.condition()
+	body := p.loopBody()
+
+	return ast.NewWhileStatement(start, test, body)
+}
+
+/**
+ * ForStatement
+ * 	: 'for' '(' OptForInit ';' OptExpression ';' OptExpression ')' BlockStatement
+ * 	| 'for' '(' Identifier 'in' Expression ')' BlockStatement
+ * 	;
+ *
+ * ForInit
+ * 	: 'let' VariableDeclarationList
+ * 	| Expression
+ * 	;
+ *
+ * The first clause is parsed as an expression and only turns out to be the
+ * variable of a for-in loop when it is followed by 'in'.
+ */
+func (p *Parser) ForStatement() ast.Statement {
+	start := p.eat(token.FOR)
+	open := p.eat(token.LPAREN)
+
+	var init ast.Node
+	switch p.peekToken.Type {
+	case token.LET:
+		init = p.VariableStatement()
+	case token.SEMI:
+		p.eat(token.SEMI)
+	default:
+		exp := p.Expression()
+		if p.match(token.IN) {
+			return p.forIn(start, open, exp)
+		}
+		init = exp
+		p.eat(token.SEMI)
+	}
+
+	var test ast.Expression
+	if !p.match(token.SEMI) {
+		test = p.Expression()
+	}
+	p.eat(token.SEMI)
+
+	var update ast.Expression
+	if !p.match(token.RPAREN) {
+		update = p.Expression()
 	}
+	p.closeDelimiter(open, token.RPAREN)
+
+	body := p.loopBody()
+
+	return ast.NewForStatement(start, init, test, update, body)
+}
+
+// forIn parses the rest of a for-in loop whose variable has been parsed as
+// the expression left.
+func (p *Parser) forIn(start token.Token, open token.Token, left ast.Expression) *ast.ForInStatement {
+	p.eat(token.IN)
+
+	if _, ok := left.(*ast.Identifier); !ok {
+		span := token.Span{Start: left.Pos(), End: left.End()}
+		p.report(diag.Errorf(diag.InvalidLoopVariable, span, "Invalid for-in variable %q", left.String()))
+		left = ast.NewBadExpression(span.Start, span.End)
+	}
+
+	right := p.Expression()
+	p.closeDelimiter(open, token.RPAREN)
 
-	p.eat(token.RBRACE)
+	body := p.loopBody()
 
-	return ast.NewBlockStatement(p.peekToken, body)
+	return ast.NewForInStatement(start, left, right, body)
+}
+
+// loopBody parses the block of a loop, in which break and continue are
+// allowed.
+func (p *Parser) loopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.BlockStatement()
+}
+
+/**
+ * BreakStatement
+ * 	: 'break' ';'
+ * 	;
+ */
+func (p *Parser) BreakStatement() *ast.BreakStatement {
+	start := p.eat(token.BREAK)
+	p.checkInLoop(start)
+	end := p.endStatement()
+
+	return ast.NewBreakStatement(start, end)
+}
+
+/**
+ * ContinueStatement
+ * 	: 'continue' ';'
+ * 	;
+ */
+func (p *Parser) ContinueStatement() *ast.ContinueStatement {
+	start := p.eat(token.CONTINUE)
+	p.checkInLoop(start)
+	end := p.endStatement()
+
+	return ast.NewContinueStatement(start, end)
+}
+
+// checkInLoop reports a break or continue statement that is not inside the
+// body of a loop of the current function.
+func (p *Parser) checkInLoop(keyword token.Token) {
+	if p.loopDepth == 0 {
+		p.report(diag.Errorf(diag.JumpOutsideLoop, keyword.Span, "Unexpected %q outside of a loop", keyword.Literal))
+	}
+}
+
+// condition parses the parenthesized test of a conditional or a loop.
+func (p *Parser) condition() ast.Expression {
+	open := p.eat(token.LPAREN)
+	test := p.Expression()
+	p.closeDelimiter(open, token.RPAREN)
+
+	return test
 }
 
 /**
  * ExpressionStatement
  * 	: Expression ';'
+ * 	| Expression    -> last in the block of an if branch
  * 	;
  */
 func (p *Parser) ExpressionStatement() *ast.ExpressionStatement {
+	start := p.peekToken
 	exp := p.Expression()
 
-	p.eat(token.SEMI)
+	if p.valueBlock && p.match(token.RBRACE) {
+		return ast.NewExpressionStatement(start, exp, token.Token{})
+	}
+	end := p.endStatement()
+
+	return ast.NewExpressionStatement(start, exp, end)
+}
+
+// endStatement eats the ';' closing a statement. A ')' left over from
+// unbalanced parentheses is reported and skipped first. Reaching the ';' ends
+// any panic mode, as the next statement starts right after it.
+func (p *Parser) endStatement() token.Token {
+	for p.match(token.RPAREN) {
+		p.syntaxError(diag.Errorf(diag.UnmatchedDelimiter, p.peekToken.Span, "Unmatched ')'").
+			WithFix("Remove the ')'", p.peekToken.Span, ""))
+		p.nextToken()
+	}
 
-	return ast.NewExpressionStatement(p.peekToken, exp)
+	if p.match(token.SEMI) {
+		p.panicking = false
+	}
+
+	return p.eat(token.SEMI)
 }
 
 /**
  * Expression
- * 	: Literal
+ * 	: BinaryExpression
  * 	;
  */
 func (p *Parser) Expression() ast.Expression {
-	return p.AdditiveExpression()
+	return p.BinaryExpression(LOWEST)
+}
+
+// Precedence levels of the binary operators, from loosest to tightest.
+const (
+	_ int = iota
+	LOWEST
+	ASSIGNMENT  // = += -= *= /= %=
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
+	EQUALS      // == !=
+	COMPARE     // < <= > >=
+	SUM         // + -
+	PRODUCT     // * / %
+)
+
+type associativity int
+
+const (
+	leftAssociative associativity = iota
+	rightAssociative
+)
+
+// operatorKind selects the node built for an infix operator.
+type operatorKind int
+
+const (
+	binaryOperation     operatorKind = iota // ast.BinaryExpression
+	logicalOperation                        // ast.LogicalExpression
+	assignmentOperation                     // ast.AssignmentExpression
+)
+
+type binaryOperator struct {
+	precedence    int
+	associativity associativity
+	kind          operatorKind
+}
+
+// binaryOperators drives BinaryExpression: supporting a new infix operator
+// only takes an entry in this table.
+var binaryOperators = map[token.TokenType]binaryOperator{
+	token.ASSIGN:          {ASSIGNMENT, rightAssociative, assignmentOperation},
+	token.PLUS_ASSIGN:     {ASSIGNMENT, rightAssociative, assignmentOperation},
+	token.MINUS_ASSIGN:    {ASSIGNMENT, rightAssociative, assignmentOperation},
+	token.ASTERISK_ASSIGN: {ASSIGNMENT, rightAssociative, assignmentOperation},
+	token.SLASH_ASSIGN:    {ASSIGNMENT, rightAssociative, assignmentOperation},
+	token.PERCENT_ASSIGN:  {ASSIGNMENT, rightAssociative, assignmentOperation},
+	token.OR:              {LOGICAL_OR, leftAssociative, logicalOperation},
+	token.AND:             {LOGICAL_AND, leftAssociative, logicalOperation},
+	token.EQ:              {EQUALS, leftAssociative, binaryOperation},
+	token.NOT_EQ:          {EQUALS, leftAssociative, binaryOperation},
+	token.LT:              {COMPARE, leftAssociative, binaryOperation},
+	token.LT_EQ:           {COMPARE, leftAssociative, binaryOperation},
+	token.GT:              {COMPARE, leftAssociative, binaryOperation},
+	token.GT_EQ:           {COMPARE, leftAssociative, binaryOperation},
+	token.PLUS:            {SUM, leftAssociative, binaryOperation},
+	token.MINUS:           {SUM, leftAssociative, binaryOperation},
+	token.ASTERISK:        {PRODUCT, leftAssociative, binaryOperation},
+	token.SLASH:           {PRODUCT, leftAssociative, binaryOperation},
+	token.PERCENT:         {PRODUCT, leftAssociative, binaryOperation},
 }
 
 /**
- * AdditiveExpression
- * 	: MultiplicativeExpression
- * 	| AdditiveExpression ADDITIVE_OPERATOR MultiplicativeExpression -> MultiplicativeExpression ADDITIVE_OPERATOR MultiplicativeExpression ADDITIVE_OPERATOR MultiplicativeExpression
+ * BinaryExpression
+ * 	: UnaryExpression
+ * 	| BinaryExpression BINARY_OPERATOR BinaryExpression
  * 	;
+ *
+ * Parsed by precedence climbing over binaryOperators. The loop keeps folding
+ * operators that bind at least as tightly as minPrecedence into the left
+ * operand. The right operand of a left-associative operator only takes
+ * tighter operators, while that of a right-associative one also takes
+ * operators of the same level.
  */
-func (p *Parser) AdditiveExpression() ast.Expression {
-	return p.BinaryExpression(p.MultiplicativeExpression, token.PLUS, token.MINUS)
+func (p *Parser) BinaryExpression(minPrecedence int) ast.Expression {
+	left := p.UnaryExpression()
+
+	for {
+		op, ok := binaryOperators[p.peekToken.Type]
+		if !ok || op.precedence < minPrecedence {
+			return left
+		}
+
+		operator := p.eat(p.peekToken.Type)
+
+		next := op.precedence + 1
+		if op.associativity == rightAssociative {
+			next = op.precedence
+		}
+		right := p.BinaryExpression(next)
+
+		switch op.kind {
+		case logicalOperation:
+			left = ast.NewLogicalExpression(operator.Literal, left, right)
+		case assignmentOperation:
+			p.checkAssignmentTarget(left)
+			left = ast.NewAssignmentExpression(operator.Literal, left, right)
+		default:
+			left = ast.NewBinaryExpression(operator.Literal, left, right)
+		}
+	}
+}
+
+// checkAssignmentTarget reports an error unless exp can be assigned to.
+func (p *Parser) checkAssignmentTarget(exp ast.Expression) {
+	switch exp.(type) {
+	case *ast.Identifier, *ast.MemberExpression:
+		return
+	}
+
+	span := token.Span{Start: exp.Pos(), End: exp.End()}
+	p.report(diag.Errorf(diag.InvalidAssignmentTarget, span, "Invalid assignment target %q", exp.String()).
+		WithNote("Only identifiers and member expressions can be assigned to"))
+}
+
+/**
+ * UnaryExpression
+ * 	: CallMemberExpression
+ * 	| UNARY_OPERATOR UnaryExpression
+ * 	;
+ *
+ * Prefix operators bind tighter than every binary operator: -2 * 3 is
+ * (-2) * 3.
+ */
+func (p *Parser) UnaryExpression() ast.Expression {
+	switch p.peekToken.Type {
+	case token.MINUS, token.PLUS, token.BANG:
+		operator := p.eat(p.peekToken.Type)
+		return ast.NewUnaryExpression(operator, p.UnaryExpression())
+	default:
+		return p.CallMemberExpression()
+	}
 }
 
 /**
- * MultiplicativeExpression
+ * CallMemberExpression
  * 	: PrimaryExpression
- * 	| MultiplicativeExpression MULTIPLICATIVE_OPERATOR PrimaryExpression -> PrimaryExpression MULTIPLICATIVE_OPERATOR PrimaryExpression MULTIPLICATIVE_OPERATOR PrimaryExpression
+ * 	| CallMemberExpression '(' OptExpressionList ')'
+ * 	| CallMemberExpression '.' Identifier
+ * 	| CallMemberExpression '[' Expression ']'
+ * 	| CallMemberExpression '[' OptExpression ':' OptExpression ']'
  * 	;
+ *
+ * Calls, member access, indexing and slicing are postfix and bind tighter
+ * than prefix operators: -f(x) is -(f(x)), and f(1)(2) calls the result of
+ * f(1). Brackets around a single expression are computed member access,
+ * whatever the expression: a["b"], xs[i] and m[k] are all member expressions.
  */
-func (p *Parser) MultiplicativeExpression() ast.Expression {
-	return p.BinaryExpression(p.PrimaryExpression, token.ASTERISK, token.SLASH, token.PERCENT)
+func (p *Parser) CallMemberExpression() ast.Expression {
+	exp := p.PrimaryExpression()
+
+	for {
+		switch p.peekToken.Type {
+		case token.LPAREN:
+			open := p.eat(token.LPAREN)
+			args := p.ExpressionList(token.RPAREN)
+			end := p.closeDelimiter(open, token.RPAREN)
+			exp = ast.NewCallExpression(open, exp, args, end)
+		case token.DOT:
+			dot := p.eat(token.DOT)
+			exp = ast.NewMemberExpression(dot, exp, p.Identifier())
+		case token.LBRACKET:
+			exp = p.index(exp)
+		default:
+			return exp
+		}
+	}
 }
 
-func (p *Parser) BinaryExpression(builder func() ast.Expression, ops ...token.TokenType) ast.Expression {
-	left := builder()
+// index parses the brackets after object, which hold either the key of a
+// computed member access or the bounds of a slice.
+func (p *Parser) index(object ast.Expression) ast.Expression {
+	open := p.eat(token.LBRACKET)
+
+	var low ast.Expression
+	if !p.match(token.COLON) {
+		low = p.Expression()
+	}
 
-	for _, op := range ops {
-		for p.match(op) {
-			var operator token.Token
-			operator = p.eat(op).(token.Token)
+	if !p.match(token.COLON) {
+		end := p.closeDelimiter(open, token.RBRACKET)
+		return ast.NewComputedMemberExpression(open, object, low, end)
+	}
 
-			right := builder()
+	p.eat(token.COLON)
 
-			left = ast.NewBinaryExpression(operator.Literal, left, right)
+	var high ast.Expression
+	if !p.match(token.RBRACKET) {
+		high = p.Expression()
+	}
+
+	end := p.closeDelimiter(open, token.RBRACKET)
+
+	return ast.NewSliceExpression(open, object, low, high, end)
+}
+
+/**
+ * ExpressionList
+ * 	: Expression
+ * 	| ExpressionList ',' Expression
+ * 	;
+ *
+ * The arguments of a call or the elements of an array, up to the closing
+ * token. A trailing comma is allowed before it.
+ */
+func (p *Parser) ExpressionList(closing token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	for !p.match(closing) && !p.isAtEnd() {
+		list = append(list, p.Expression())
+		if !p.match(token.COMMA) {
+			break
 		}
+		p.eat(token.COMMA)
 	}
 
-	return left
+	return list
 }
 
 /**
  * PrimaryExpression
  * 	: Literal
  *	| GroupedExpression
+ *	| Identifier
+ *	| IfExpression
+ *	| ArrayLiteral
+ *	| MapLiteral
+ *	| FunctionLiteral
  * 	;
  */
 func (p *Parser) PrimaryExpression() ast.Expression {
 	switch p.peekToken.Type {
 	case token.LPAREN:
+		if p.isArrowFunction() {
+			return p.FunctionLiteral()
+		}
 		return p.GroupedExpression()
+	case token.FUNCTION:
+		return p.FunctionLiteral()
+	case token.ILLEGAL:
+		// The lexer has already reported the token.
+		tok := p.eat(token.ILLEGAL)
+		return ast.NewBadExpression(tok.Span.Start, tok.Span.End)
+	case token.LBRACKET:
+		return p.ArrayLiteral()
+	case token.LBRACE:
+		return p.MapLiteral()
+	case token.IDENT:
+		return p.Identifier()
+	case token.IF:
+		return p.IfExpression()
 	default:
 		return p.Literal()
 	}
 }
 
+/**
+ * FunctionLiteral
+ * 	: 'def' FormalParameterList BlockStatement
+ * 	| FormalParameterList '->' ArrowFunctionBody
+ * 	;
+ *
+ * ArrowFunctionBody
+ * 	: BlockStatement
+ * 	| Expression
+ * 	;
+ *
+ * A '{' after the arrow always opens a block, never a map literal.
+ */
+func (p *Parser) FunctionLiteral() *ast.FunctionLiteral {
+	start := p.peekToken
+
+	if p.match(token.FUNCTION) {
+		p.eat(token.FUNCTION)
+		params := p.FormalParameterList()
+		body := p.functionBody()
+
+		return ast.NewFunctionLiteral(start, params, body)
+	}
+
+	params := p.FormalParameterList()
+	p.eat(token.ARROW)
+
+	var body ast.Node
+	if p.match(token.LBRACE) {
+		body = p.functionBody()
+	} else {
+		body = p.Expression()
+	}
+
+	return ast.NewFunctionLiteral(start, params, body)
+}
+
+// isArrowFunction tells whether the '(' at peekToken opens the parameter list
+// of an arrow function rather than a grouped expression, by scanning ahead
+// over the identifiers and commas of the list for a '->' after it.
+func (p *Parser) isArrowFunction() bool {
+	for i := 1; ; i++ {
+		switch p.lookAhead(i).Type {
+		case token.IDENT, token.COMMA:
+		case token.RPAREN:
+			return p.lookAhead(i+1).Type == token.ARROW
+		default:
+			return false
+		}
+	}
+}
+
 /**
  * GroupedExpression
- * 	: Literal
+ * 	: '(' Expression ')'
  * 	;
  */
 func (p *Parser) GroupedExpression() ast.Expression {
-	p.eat(token.LPAREN)
+	open := p.eat(token.LPAREN)
 	exp := p.Expression()
-	p.eat(token.RPAREN)
+	p.closeDelimiter(open, token.RPAREN)
 
 	return exp
 }
 
+/**
+ * IfExpression
+ * 	: 'if' '(' Expression ')' BlockStatement
+ * 	| 'if' '(' Expression ')' BlockStatement 'else' BlockStatement
+ * 	| 'if' '(' Expression ')' BlockStatement 'else' IfExpression
+ * 	;
+ *
+ * The expression form of IfStatement, for conditionals in value position:
+ * let x = if (a) { 1 } else { 2 };
+ */
+func (p *Parser) IfExpression() *ast.IfExpression {
+	return p.conditional(func(start token.Token, test ast.Expression, consequent *ast.BlockStatement, alternate ast.Node) ast.Node {
+		return ast.NewIfExpression(start, test, consequent, alternate)
+	}).(*ast.IfExpression)
+}
+
+/**
+ * ArrayLiteral
+ * 	: '[' OptExpressionList ']'
+ * 	;
+ */
+func (p *Parser) ArrayLiteral() *ast.ArrayLiteral {
+	start := p.eat(token.LBRACKET)
+	elements := p.ExpressionList(token.RBRACKET)
+	end := p.closeDelimiter(start, token.RBRACKET)
+
+	return ast.NewArrayLiteral(start, elements, end)
+}
+
+/**
+ * MapLiteral
+ * 	: '{' OptMapEntryList '}'
+ * 	;
+ *
+ * MapEntryList
+ * 	: MapEntry
+ * 	| MapEntryList ',' MapEntry
+ * 	;
+ *
+ * A trailing comma is allowed before the closing '}'.
+ */
+func (p *Parser) MapLiteral() *ast.MapLiteral {
+	start := p.eat(token.LBRACE)
+
+	entries := []*ast.MapEntry{}
+	for !p.match(token.RBRACE) && !p.isAtEnd() {
+		entries = append(entries, p.MapEntry())
+		if !p.match(token.COMMA) {
+			break
+		}
+		p.eat(token.COMMA)
+	}
+
+	end := p.closeDelimiter(start, token.RBRACE)
+
+	return ast.NewMapLiteral(start, entries, end)
+}
+
+/**
+ * MapEntry
+ * 	: MapKey ':' Expression
+ * 	;
+ *
+ * MapKey
+ * 	: Identifier
+ * 	| StringLiteral
+ * 	;
+ */
+func (p *Parser) MapEntry() *ast.MapEntry {
+	var key ast.Expression
+	switch p.peekToken.Type {
+	case token.IDENT:
+		key = p.Identifier()
+	case token.STRING:
+		key = p.StringLiteral()
+	default:
+		p.syntaxError(diag.Errorf(diag.InvalidMapKey, p.peekToken.Span, "Invalid map key %q", p.peekToken.Literal).
+			WithNote("Map keys are identifiers or string literals"))
+		key = p.Expression()
+	}
+
+	p.eat(token.COLON)
+	value := p.Expression()
+
+	return ast.NewMapEntry(key, value)
+}
+
+/**
+ * Identifier
+ * 	: IDENT
+ * 	;
+ */
+func (p *Parser) Identifier() *ast.Identifier {
+	return ast.NewIdentifier(p.eat(token.IDENT))
+}
+
 /**
  * Literal
  * 	: IntegerLiteral
  * 	| FloatLiteral
  * 	| StringLiteral
+ * 	| InterpolatedString
+ * 	| BooleanLiteral
+ * 	| NilLiteral
  * 	;
  */
 func (p *Parser) Literal() ast.Expression {
 	switch p.peekToken.Type {
 	case token.INT:
 		return p.IntegerLiteral()
+	case token.FLOAT:
+		return p.FloatLiteral()
 	case token.STRING:
 		return p.StringLiteral()
+	case token.STRING_HEAD:
+		return p.InterpolatedString()
+	case token.TRUE, token.FALSE:
+		return p.BooleanLiteral()
+	case token.NIL:
+		return p.NilLiteral()
 	default:
-		msg := fmt.Sprintf("Unexpected literal %q at line %d", p.peekToken.Type, p.peekToken.Line)
-		p.errors = append(p.errors, msg)
-		//geroError.PrintError(geroError.PARSER_ERROR, msg)
-		return nil
+		tok := p.peekToken
+		p.syntaxError(diag.Errorf(diag.UnexpectedToken, tok.Span, "Unexpected literal %q", tok.Type))
+		if p.startsStatement() && tok.Span.Start.Line == p.prevEnd.Line {
+			// A keyword such as 'while' cannot start an expression. Skip it, or
+			// recovery would restart at it and report the mistake again. One
+			// that starts a line is left to start the next statement.
+			p.nextToken()
+			return ast.NewBadExpression(tok.Span.Start, tok.Span.End)
+		}
+		return ast.NewBadExpression(tok.Span.Start, tok.Span.Start)
 	}
 }
 
 func (p *Parser) IntegerLiteral() *ast.IntegerLiteral {
-	tok, ok := p.eat(token.INT).(token.Token)
-	if !ok {
-		return nil
+	tok := p.eat(token.INT)
+
+	digits := strings.ReplaceAll(tok.Literal, "_", "")
+	base := 0
+	if len(digits) > 1 && digits[0] == '0' && '0' <= digits[1] && digits[1] <= '9' {
+		// Unlike Go, a leading zero does not make a literal octal.
+		base = 10
 	}
-	value, err := strconv.ParseInt(tok.Literal, 0, 64)
+
+	value, err := strconv.ParseInt(digits, base, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer at line %d", tok.Literal, tok.Line)
-		p.errors = append(p.errors, msg)
-		//geroError.PrintError(geroError.PARSER_ERROR, msg)
+		d := diag.Errorf(diag.InvalidNumber, tok.Span, "could not parse %q as integer", tok.Literal)
+		if errors.Is(err, strconv.ErrRange) {
+			d = diag.Errorf(diag.NumberOutOfRange, tok.Span, "Integer literal %q is out of range", tok.Literal)
+		}
+		p.report(d)
 	}
 
 	return ast.NewIntegerLiteral(tok, int64(value))
 }
 
+func (p *Parser) FloatLiteral() *ast.FloatLiteral {
+	tok := p.eat(token.FLOAT)
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(tok.Literal, "_", ""), 64)
+	if err != nil {
+		d := diag.Errorf(diag.InvalidNumber, tok.Span, "could not parse %q as float", tok.Literal)
+		if errors.Is(err, strconv.ErrRange) {
+			d = diag.Errorf(diag.NumberOutOfRange, tok.Span, "Float literal %q is out of range", tok.Literal)
+		}
+		p.report(d)
+	}
+
+	return ast.NewFloatLiteral(tok, value)
+}
+
+/**
+ * BooleanLiteral
+ * 	: 'true'
+ * 	| 'false'
+ * 	;
+ */
+func (p *Parser) BooleanLiteral() *ast.BooleanLiteral {
+	tok := p.eat(p.peekToken.Type)
+	return ast.NewBooleanLiteral(tok, tok.Type == token.TRUE)
+}
+
+/**
+ * NilLiteral
+ * 	: 'nil'
+ * 	;
+ */
+func (p *Parser) NilLiteral() *ast.NilLiteral {
+	return ast.NewNilLiteral(p.eat(token.NIL))
+}
+
 func (p *Parser) StringLiteral() *ast.StringLiteral {
-	tok, ok := p.eat(token.STRING).(token.Token)
-	if !ok {
-		return nil
+	tok := p.eat(token.STRING)
+	// Invalid escapes have already been reported by the lexer.
+	value, _ := lexer.Unquote(tok.Literal)
+	return ast.NewStringLiteral(tok, value)
+}
+
+/**
+ * InterpolatedString
+ * 	: STRING_HEAD Expression (STRING_MIDDLE Expression)* STRING_TAIL
+ * 	;
+ */
+func (p *Parser) InterpolatedString() *ast.InterpolatedString {
+	head := p.eat(token.STRING_HEAD)
+	quote := lexer.Delimiter(head.Literal)
+
+	var parts []ast.Expression
+	parts = p.appendFragment(parts, head, quote)
+	parts = append(parts, p.Expression())
+
+	for p.match(token.STRING_MIDDLE) {
+		parts = p.appendFragment(parts, p.eat(token.STRING_MIDDLE), quote)
+		parts = append(parts, p.Expression())
 	}
-	return ast.NewStringLiteral(tok, tok.Literal[1:len(tok.Literal)-1])
+
+	if !p.match(token.STRING_TAIL) {
+		if p.isAtEnd() {
+			// The lexer has reported the unterminated string.
+			p.panicking = true
+		} else {
+			p.eat(token.STRING_TAIL)
+		}
+		// Close the last placeholder with an empty tail where the string
+		// broke off, so that the node still prints and spans like a string.
+		at := token.Span{Start: p.peekToken.Span.Start, End: p.peekToken.Span.Start}
+		return ast.NewInterpolatedString(head, parts, token.Token{Type: token.STRING_TAIL, Literal: "}" + quote, Span: at})
+	}
+
+	tail := p.eat(token.STRING_TAIL)
+	parts = p.appendFragment(parts, tail, quote)
+
+	return ast.NewInterpolatedString(head, parts, tail)
 }
 
-func (p *Parser) eat(tokenType token.TokenType) interface{} {
+// appendFragment adds the text of a string segment to the parts of an
+// interpolated string, leaving out empty text between placeholders.
+func (p *Parser) appendFragment(parts []ast.Expression, tok token.Token, quote string) []ast.Expression {
+	// Invalid escapes have already been reported by the lexer. A segment is
+	// only left out when it has no text at all.
+	value, err := lexer.UnquoteSegment(tok, quote)
+	if value == "" && err == nil {
+		return parts
+	}
+	return append(parts, ast.NewStringLiteral(tok, value))
+}
+
+// eat consumes the lookahead token if it has the expected type. On a mismatch
+// it records an error and returns the zero token without consuming anything.
+func (p *Parser) eat(tokenType token.TokenType) token.Token {
 	curToken := p.peekToken
 
 	if curToken.Type != tokenType {
-		msg := fmt.Sprintf("Unexpected token %q, expected %q at line %d", curToken.Type, tokenType, curToken.Line)
-		p.errors = append(p.errors, msg)
-		//geroError.PrintError(geroError.PARSER_ERROR, msg)
-		return nil
+		p.syntaxError(diag.Errorf(diag.UnexpectedToken, curToken.Span, "Unexpected token %q, expected %q", curToken.Type, tokenType))
+		return token.Token{}
 	}
 
-	tok, ok := p.l.NextToken().(token.Token)
-	if !ok {
-		return nil
+	p.nextToken()
+
+	return curToken
+}
+
+// closeDelimiter eats the closing token matching the open '(', '[' or '{' token,
+// reporting where the unclosed delimiter started if it is missing.
+func (p *Parser) closeDelimiter(open token.Token, closing token.TokenType) token.Token {
+	if p.match(closing) {
+		return p.eat(closing)
 	}
 
-	p.peekToken = tok
+	at := token.Span{Start: p.peekToken.Span.Start, End: p.peekToken.Span.Start}
+	p.syntaxError(diag.Errorf(diag.UnclosedDelimiter, p.peekToken.Span, "Expected '%s' to close '%s' at %s, got %q", closing, open.Literal, open.Span.Start, p.peekToken.Type).
+		WithLabel(open.Span, fmt.Sprintf("Unclosed '%s'", open.Literal)).
+		WithFix(fmt.Sprintf("Insert '%s'", closing), at, string(closing)))
 
-	return curToken
+	return token.Token{}
+}
+
+// report records an error that leaves the parse on track, such as an invalid
+// assignment target.
+func (p *Parser) report(d diag.Diagnostic) {
+	p.diags.Report(d)
+}
+
+// syntaxError records a syntax error and enters panic mode, unless the parser
+// is already recovering from an earlier one. An error at an ILLEGAL token only
+// enters panic mode, as the lexer has already reported the token.
+func (p *Parser) syntaxError(d diag.Diagnostic) {
+	if p.panicking {
+		return
+	}
+	if !p.match(token.ILLEGAL) {
+		p.diags.Report(d)
+	}
+	p.panicking = true
+}
+
+// nextToken moves to the next lookahead token.
+func (p *Parser) nextToken() {
+	p.prevEnd = p.peekToken.Span.End
+	if len(p.ahead) > 0 {
+		p.peekToken = p.ahead[0]
+		p.ahead = p.ahead[1:]
+		return
+	}
+	p.peekToken = p.l.NextToken()
+}
+
+// lookAhead returns the token n tokens past peekToken without consuming
+// anything.
+func (p *Parser) lookAhead(n int) token.Token {
+	if n == 0 {
+		return p.peekToken
+	}
+	for len(p.ahead) < n {
+		p.ahead = append(p.ahead, p.l.NextToken())
+	}
+	return p.ahead[n-1]
 }
 
 func (p *Parser) isAtEnd() bool {
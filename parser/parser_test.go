@@ -2,9 +2,11 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"testing"
 
 	"github.com/jellycat-io/gero/ast"
+	"github.com/jellycat-io/gero/diag"
 	"github.com/jellycat-io/gero/lexer"
 )
 
@@ -24,6 +26,69 @@ func TestParsingStatementList(t *testing.T) {
 	}
 }
 
+func TestParsingEmptyProgram(t *testing.T) {
+	tests := []string{
+		"",
+		"   \t\n\n  ",
+		"// only a comment",
+		"/* a block\n   comment */\n// and a line comment\n",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 0 {
+			t.Errorf("Program %q has wrong number of statements. Expected=0, got=%d", input, len(program.Statements))
+		}
+		if program.String() != "" {
+			t.Errorf("Program %q is not empty, got=%q", input, program.String())
+		}
+	}
+}
+
+func TestParsingEmptyBlocks(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{ }", ""},
+		{"{ // nothing here\n}", ""},
+		{"{ {} { /* empty */ } }", ""},
+		{"def f() { /* todo */ }", "def f() {}"},
+		{"while (a) {\n}", "while (a) {}"},
+		{"let f = () -> {};", "let f = () -> {};"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Program %q has wrong number of statements. Expected=1, got=%d", tt.input, len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("Wrong program for %q. Expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestUnclosedBlock(t *testing.T) {
+	l := lexer.New("{ 1;")
+	p := New(l)
+	p.Program()
+
+	expected := `Unexpected token "EOF", expected "}" at 1:5`
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0].String() != expected {
+		t.Errorf("Wrong errors. Expected=%q, got=%q", expected, errors)
+	}
+}
+
 func TestParsingExpressionStatement(t *testing.T) {
 	input := `
 		5;
@@ -89,18 +154,17 @@ func TestParsingBlockStatement(t *testing.T) {
 	}
 }
 
-func TestParsingBinaryExpression(t *testing.T) {
+func TestParsingVariableDeclaration(t *testing.T) {
 	tests := []struct {
 		input    string
-		left     interface{}
-		operator string
-		right    interface{}
+		names    []string
+		inits    []interface{}
+		expected string
 	}{
-		{"2 + 2;", 2, "+", 2},
-		{"2 - 2;", 2, "-", 2},
-		{"2 * 2;", 2, "*", 2},
-		{"2 / 2;", 2, "/", 2},
-		{"2 % 2;", 2, "%", 2},
+		{"let x = 5;", []string{"x"}, []interface{}{5}, "let x = 5;"},
+		{"let y;", []string{"y"}, []interface{}{nil}, "let y;"},
+		{`let a = 1, b, c = "hi";`, []string{"a", "b", "c"}, []interface{}{1, nil, "hi"}, `let a = 1, b, c = "hi";`},
+		{"let z = 1 + 2 * 3;", []string{"z"}, []interface{}{nil}, "let z = (1 + (2 * 3));"},
 	}
 
 	for _, tt := range tests {
@@ -109,16 +173,30 @@ func TestParsingBinaryExpression(t *testing.T) {
 		program := p.Program()
 		checkParserErrors(t, p)
 
-		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		decl, ok := program.Statements[0].(*ast.VariableDeclaration)
+		if !ok {
+			t.Fatalf("program.Body[0] is not ast.VariableDeclaration, got=%T", program.Statements[0])
+		}
 
-		if !testBinaryExpression(t, stmt.Expression, tt.left, tt.operator, tt.right) {
-			return
+		if len(decl.Declarations) != len(tt.names) {
+			t.Fatalf("Wrong number of declarations. Expected=%d, got=%d", len(tt.names), len(decl.Declarations))
+		}
+
+		for i, d := range decl.Declarations {
+			testIdentifier(t, d.Id, tt.names[i])
+			if tt.inits[i] != nil {
+				testLiteralExpression(t, d.Init, tt.inits[i])
+			}
+		}
+
+		if decl.String() != tt.expected {
+			t.Errorf("String() is incorrect. Expected=%s, got=%s", tt.expected, decl.String())
 		}
 	}
 }
 
-func TestParsingIntegerLiteral(t *testing.T) {
-	input := `5;`
+func TestParsingIdentifier(t *testing.T) {
+	input := `foo;`
 
 	l := lexer.New(input)
 	p := New(l)
@@ -127,135 +205,1762 @@ func TestParsingIntegerLiteral(t *testing.T) {
 
 	stmt := program.Statements[0].(*ast.ExpressionStatement)
 
-	testLiteralExpression(t, stmt.Expression, 5)
+	testIdentifier(t, stmt.Expression, "foo")
 }
 
-func TestParsingStringLiteral(t *testing.T) {
-	input := `
-		"hello world";
-		'hello world';
-	`
+func TestParsingAssignmentExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    interface{}
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += 5;", "x", "+=", 5},
+		{"x -= 5;", "x", "-=", 5},
+		{"x *= 5;", "x", "*=", 5},
+		{"x /= 5;", "x", "/=", 5},
+		{"x %= 5;", "x", "%=", 5},
+		{`x = "hi";`, "x", "=", "hi"},
+	}
 
-	l := lexer.New(input)
-	p := New(l)
-	program := p.Program()
-	checkParserErrors(t, p)
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	testLiteralExpression(t, stmt.Expression, "hello world")
-	stmt = program.Statements[1].(*ast.ExpressionStatement)
-	testLiteralExpression(t, stmt.Expression, "hello world")
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		ae, ok := stmt.Expression.(*ast.AssignmentExpression)
+		if !ok {
+			t.Fatalf("Expression is not *ast.AssignmentExpression. got=%T", stmt.Expression)
+		}
+		testIdentifier(t, ae.Left, tt.target)
+		if ae.Operator != tt.operator {
+			t.Fatalf("Operator is not %q. got=%q", tt.operator, ae.Operator)
+		}
+		testLiteralExpression(t, ae.Right, tt.value)
+	}
 }
 
-func TestOperatorPrecedence(t *testing.T) {
+func TestInvalidAssignmentTarget(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected string
 	}{
-		{
-			"2 + 2 * 2;",
-			"(2 + (2 * 2))",
-		},
-		{
-			"2 * 2 + 2;",
-			"((2 * 2) + 2)",
-		},
-		{
-			"(2 + 2) * 2;",
-			"((2 + 2) * 2)",
-		},
-		{
-			"2 - 2 / 2;",
-			"(2 - (2 / 2))",
-		},
-		{
-			"2 / 2 - 2;",
-			"((2 / 2) - 2)",
-		},
-		{
-			"(2 - 2) / 2;",
-			"((2 - 2) / 2)",
-		},
+		{"5 = x;", `Invalid assignment target "5" at 1:1`},
+		{"a + b = c;", `Invalid assignment target "(a + b)" at 1:1`},
+		{"-x += 1;", `Invalid assignment target "(-x)" at 1:1`},
+		{"a = 1 = b;", `Invalid assignment target "1" at 1:5`},
 	}
 
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l)
-		actual := p.Program().String()
+		p.Program()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].String() != tt.expected {
+			t.Errorf("Wrong errors for %q. Expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestParsingFunctionDeclaration(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		params   []string
+		body     int
+		expected string
+	}{
+		{"def add(a, b) { return a + b; }", "add", []string{"a", "b"}, 1, "def add(a, b) {return (a + b);}"},
+		{"def noop() {}", "noop", []string{}, 0, "def noop() {}"},
+		{"def f(x,) { let y = x; return; }", "f", []string{"x"}, 2, "def f(x) {let y = x;return;}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
 		checkParserErrors(t, p)
 
-		fmt.Println(actual)
+		fn, ok := program.Statements[0].(*ast.FunctionDeclaration)
+		if !ok {
+			t.Fatalf("program.Body[0] is not ast.FunctionDeclaration, got=%T", program.Statements[0])
+		}
 
-		if actual != tt.expected {
-			t.Errorf("Program is incorrect. Expected=%v+, got=%v+", tt.expected, actual)
+		testIdentifier(t, fn.Name, tt.name)
+
+		if len(fn.Params) != len(tt.params) {
+			t.Fatalf("Wrong number of parameters. Expected=%d, got=%d", len(tt.params), len(fn.Params))
+		}
+		for i, param := range fn.Params {
+			testIdentifier(t, param, tt.params[i])
+		}
+
+		if len(fn.Body.Body) != tt.body {
+			t.Fatalf("Function body has wrong number of statements. Expected=%d, got=%d", tt.body, len(fn.Body.Body))
+		}
+
+		if fn.String() != tt.expected {
+			t.Errorf("String() is incorrect. Expected=%s, got=%s", tt.expected, fn.String())
 		}
 	}
 }
 
-func checkParserErrors(t *testing.T, p *Parser) {
-	errors := p.Errors()
-	if len(errors) == 0 {
-		return
+func TestParsingReturnStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		argument interface{}
+	}{
+		{"return 5;", 5},
+		{"return true;", true},
+		{"return;", nil},
 	}
 
-	t.Errorf("Parser has %d errors", len(errors))
-	for _, msg := range errors {
-		t.Errorf("Parser error: %q", msg)
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ReturnStatement)
+		if !ok {
+			t.Fatalf("program.Body[0] is not ast.ReturnStatement, got=%T", program.Statements[0])
+		}
+
+		if tt.argument == nil {
+			if stmt.Argument != nil {
+				t.Fatalf("ReturnStatement.Argument is not nil. got=%T", stmt.Argument)
+			}
+			continue
+		}
+		testLiteralExpression(t, stmt.Argument, tt.argument)
 	}
-	t.FailNow()
 }
 
-func testBinaryExpression(
-	t *testing.T,
-	exp ast.Expression,
-	left interface{},
-	operator string,
-	right interface{},
-) bool {
-	be, ok := exp.(*ast.BinaryExpression)
+func TestParsingFunctionLiteral(t *testing.T) {
+	input := "let add = def(a, b) { return a + b; };"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	init := program.Statements[0].(*ast.VariableDeclaration).Declarations[0].Init
+	fn, ok := init.(*ast.FunctionLiteral)
 	if !ok {
-		t.Errorf("Expression is not *ast.BinaryExpression. got=%T", be)
-		return false
+		t.Fatalf("Init is not ast.FunctionLiteral, got=%T", init)
 	}
-	if !testLiteralExpression(t, be.Left, left) {
-		return false
+
+	if len(fn.Params) != 2 {
+		t.Fatalf("Wrong number of parameters. Expected=2, got=%d", len(fn.Params))
 	}
-	if be.Operator != operator {
-		return false
+	testIdentifier(t, fn.Params[0], "a")
+	testIdentifier(t, fn.Params[1], "b")
+
+	body, ok := fn.Body.(*ast.BlockStatement)
+	if !ok {
+		t.Fatalf("fn.Body is not ast.BlockStatement, got=%T", fn.Body)
 	}
-	if !testLiteralExpression(t, be.Right, right) {
-		return false
+	if len(body.Body) != 1 {
+		t.Fatalf("Function body has wrong number of statements. Expected=1, got=%d", len(body.Body))
 	}
+}
 
-	return true
+func TestParsingArrowFunction(t *testing.T) {
+	input := "(a, b) -> a + b;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral, got=%T", stmt.Expression)
+	}
+
+	if len(fn.Params) != 2 {
+		t.Fatalf("Wrong number of parameters. Expected=2, got=%d", len(fn.Params))
+	}
+	testIdentifier(t, fn.Params[0], "a")
+	testIdentifier(t, fn.Params[1], "b")
+
+	body, ok := fn.Body.(ast.Expression)
+	if !ok {
+		t.Fatalf("fn.Body is not ast.Expression, got=%T", fn.Body)
+	}
+	if body.String() != "(a + b)" {
+		t.Errorf("fn.Body is incorrect. Expected=%s, got=%s", "(a + b)", body.String())
+	}
 }
 
-func testLiteralExpression(
-	t *testing.T,
-	exp ast.Expression,
-	expected interface{},
-) bool {
-	switch v := expected.(type) {
-	case int:
-		return testIntegerLiteral(t, exp, int64(v))
-	case int64:
-		return testIntegerLiteral(t, exp, v)
-	case string:
-		return testStringLiteral(t, exp, v)
+func TestParsingFunctionLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"def() {};", "def() {}"},
+		{"def(x,) { return x; };", "def(x) {return x;}"},
+		{"() -> 1;", "() -> 1"},
+		{"(x) -> { return x * 2; };", "(x) -> {return (x * 2);}"},
+		{"(x) -> (y) -> x + y;", "(x) -> (y) -> (x + y)"},
+		{"map(xs, (x) -> x * 2);", "map(xs, (x) -> (x * 2))"},
+		{"reduce(xs, def(acc, x) { return acc + x; }, 0);", "reduce(xs, def(acc, x) {return (acc + x);}, 0)"},
+		{"(a) + (b);", "(a + b)"},
+		{"(f)(x);", "f(x)"},
+		{"def(a) { return a; }(1);", "def(a) {return a;}(1)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		actual := program.Statements[0].String()
+		if actual != tt.expected {
+			t.Errorf("Wrong expression for %q. Expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
 	}
-	t.Errorf("type of exp not handled. got=%T", exp)
-	return false
 }
 
-func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
-	lit, ok := il.(*ast.IntegerLiteral)
+func TestParsingIfStatement(t *testing.T) {
+	input := "if (1 < 2) { x; } else { y; }"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.IfStatement)
 	if !ok {
-		t.Errorf("Literal is not *ast.IntegerLiteral. got=%T", il)
-		return false
+		t.Fatalf("program.Body[0] is not ast.IfStatement, got=%T", program.Statements[0])
 	}
 
-	if lit.Value != value {
-		t.Errorf("Literal.Value not %d. got=%d", value, lit.Value)
+	testBinaryExpression(t, stmt.Test, 1, "<", 2)
+
+	if len(stmt.Consequent.Body) != 1 {
+		t.Fatalf("Consequent has wrong number of statements. Expected=1, got=%d", len(stmt.Consequent.Body))
+	}
+	testIdentifier(t, stmt.Consequent.Body[0].(*ast.ExpressionStatement).Expression, "x")
+
+	alternate, ok := stmt.Alternate.(*ast.BlockStatement)
+	if !ok {
+		t.Fatalf("stmt.Alternate is not ast.BlockStatement, got=%T", stmt.Alternate)
+	}
+	testIdentifier(t, alternate.Body[0].(*ast.ExpressionStatement).Expression, "y")
+}
+
+func TestParsingIfStatementForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (a) { b; }", "if (a) {b}"},
+		{"if (a) {}", "if (a) {}"},
+		{"if (a) { 1; } else if (b) { 2; } else { 3; }", "if (a) {1} else if (b) {2} else {3}"},
+		{"if (a && !b) { return f(a); }", "if ((a && (!b))) {return f(a);}"},
+		{"if (a) { if (b) { c; } else { d; } }", "if (a) {if (b) {c} else {d}}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Wrong number of statements for %q. Expected=1, got=%d", tt.input, len(program.Statements))
+		}
+
+		actual := program.Statements[0].String()
+		if actual != tt.expected {
+			t.Errorf("Wrong if statement for %q. Expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestParsingIfExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = if (a) { 1 } else { 2 };", "let x = if (a) {1} else {2};"},
+		{"let x = if (a) { 1 } else if (b) { 2 } else { 3 };", "let x = if (a) {1} else if (b) {2} else {3};"},
+		{"f(if (a) { 1 } else { 2 });", "f(if (a) {1} else {2})"},
+		{"x = 1 + if (a) { let y = 2; y } else { 3 };", "(x = (1 + if (a) {let y = 2;y} else {3}))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		actual := program.Statements[0].String()
+		if actual != tt.expected {
+			t.Errorf("Wrong if expression for %q. Expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+
+	l := lexer.New("let x = if (a) { 1 } else { 2 };")
+	p := New(l)
+	program := p.Program()
+
+	init := program.Statements[0].(*ast.VariableDeclaration).Declarations[0].Init
+	exp, ok := init.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("Init is not ast.IfExpression, got=%T", init)
+	}
+	testIdentifier(t, exp.Test, "a")
+	testLiteralExpression(t, exp.Consequent.Body[0].(*ast.ExpressionStatement).Expression, 1)
+	testLiteralExpression(t, exp.Alternate.(*ast.BlockStatement).Body[0].(*ast.ExpressionStatement).Expression, 2)
+}
+
+func TestSemicolonOnlyOptionalInIfBranch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (a) { let x = 1 }", `Unexpected token "}", expected ";" at 1:23`},
+		{"while (a) { break }", `Unexpected token "}", expected ";" at 1:19`},
+		{"def f() { return 1 }", `Unexpected token "}", expected ";" at 1:20`},
+		{"let x = if (a) { return 1 } else { 2 };", `Unexpected token "}", expected ";" at 1:27`},
+		{"let x = if (a) { while (b) { c } 1 } else { 2 };", `Unexpected token "}", expected ";" at 1:32`},
+		{"let f = if (a) { def() { 1 } } else { nil };", `Unexpected token "}", expected ";" at 1:28`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.Program()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].String() != tt.expected {
+			t.Errorf("Wrong errors for %q. Expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestSemicolonAfterBlock(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   string
+		statements int
+	}{
+		{"if (a) { 1 } else { 2 };", "if (a) {1} else {2};", 2},
+		{"if (a) { 1; } else { 2; };", "if (a) {1} else {2};", 2},
+		{"def f() {};", "def f() {};", 2},
+		{"while (a) {};", "while (a) {};", 2},
+		{"{ x; };;", "x;;", 3},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != tt.statements {
+			t.Fatalf("Wrong number of statements for %q. Expected=%d, got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+		if _, ok := program.Statements[1].(*ast.EmptyStatement); !ok {
+			t.Errorf("Statements[1] is not ast.EmptyStatement for %q, got=%T", tt.input, program.Statements[1])
+		}
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("Wrong program for %q. Expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestParsingWhileStatement(t *testing.T) {
+	input := "while (1 < 2) { x += 1; }"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Body[0] is not ast.WhileStatement, got=%T", program.Statements[0])
+	}
+
+	testBinaryExpression(t, stmt.Test, 1, "<", 2)
+
+	if len(stmt.Body.Body) != 1 {
+		t.Fatalf("Loop body has wrong number of statements. Expected=1, got=%d", len(stmt.Body.Body))
+	}
+}
+
+func TestParsingForStatement(t *testing.T) {
+	input := "for (let i = 0; i < 10; i += 1) { f(i); }"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Body[0] is not ast.ForStatement, got=%T", program.Statements[0])
+	}
+
+	if _, ok := stmt.Init.(*ast.VariableDeclaration); !ok {
+		t.Errorf("stmt.Init is not ast.VariableDeclaration, got=%T", stmt.Init)
+	}
+	if stmt.Test.String() != "(i < 10)" {
+		t.Errorf("stmt.Test is incorrect. Expected=%s, got=%s", "(i < 10)", stmt.Test.String())
+	}
+	if stmt.Update.String() != "(i += 1)" {
+		t.Errorf("stmt.Update is incorrect. Expected=%s, got=%s", "(i += 1)", stmt.Update.String())
+	}
+}
+
+func TestParsingForInStatement(t *testing.T) {
+	input := "for (x in xs) { f(x); }"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Body[0] is not ast.ForInStatement, got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Left, "x")
+	testIdentifier(t, stmt.Right, "xs")
+}
+
+func TestParsingLoopForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (true) { break; }", "while (true) {break;}"},
+		{"for (;;) { continue; }", "for (;;) {continue;}"},
+		{"for (i = 0; i < n;) {}", "for ((i = 0); (i < n);) {}"},
+		{"for (let i = 0, j = n; i < j; i += 1) {}", "for (let i = 0, j = n; (i < j); (i += 1)) {}"},
+		{"for (x in f(xs)) { if (x) { break; } }", "for (x in f(xs)) {if (x) {break;}}"},
+		{"while (a) { for (b in c) { continue; } break; }", "while (a) {for (b in c) {continue;}break;}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		actual := program.Statements[0].String()
+		if actual != tt.expected {
+			t.Errorf("Wrong loop for %q. Expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestBreakAndContinueOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", `Unexpected "break" outside of a loop at 1:1`},
+		{"if (a) { continue; }", `Unexpected "continue" outside of a loop at 1:10`},
+		{"while (a) {} break;", `Unexpected "break" outside of a loop at 1:14`},
+		{"while (a) { def f() { break; } }", `Unexpected "break" outside of a loop at 1:23`},
+		{"for (1 in xs) {}", `Invalid for-in variable "1" at 1:6`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.Program()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].String() != tt.expected {
+			t.Errorf("Wrong errors for %q. Expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestParsingCallExpression(t *testing.T) {
+	input := "add(1, 2 * 3, x);"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression, got=%T", stmt.Expression)
+	}
+
+	testIdentifier(t, call.Callee, "add")
+
+	if len(call.Arguments) != 3 {
+		t.Fatalf("Wrong number of arguments. Expected=3, got=%d", len(call.Arguments))
+	}
+
+	testLiteralExpression(t, call.Arguments[0], 1)
+	testBinaryExpression(t, call.Arguments[1], 2, "*", 3)
+	testIdentifier(t, call.Arguments[2], "x")
+}
+
+func TestParsingCallExpressionForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f();", "f()"},
+		{"f(1, 2,);", "f(1, 2)"},
+		{"make()(3);", "make()(3)"},
+		{"f(g(1), h(2, 3));", "f(g(1), h(2, 3))"},
+		{"(f)(x);", "f(x)"},
+		{"-f(x);", "(-f(x))"},
+		{"f(x) + g(y) * 2;", "(f(x) + (g(y) * 2))"},
+		{"x = f(a = 1);", "(x = f((a = 1)))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		actual := program.Statements[0].(*ast.ExpressionStatement).Expression.String()
+		if actual != tt.expected {
+			t.Errorf("Wrong call for %q. Expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestUnbalancedParentheses(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(1, 2;", `Expected ')' to close '(' at 1:2, got ";" at 1:7`},
+		{"(1 + 2;", `Expected ')' to close '(' at 1:1, got ";" at 1:7`},
+		{"f(1));", `Unmatched ')' at 1:5`},
+		{"let x = (1));", `Unmatched ')' at 1:12`},
+		{"def f(a { return a; }", `Expected ')' to close '(' at 1:6, got "{" at 1:9`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.Program()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].String() != tt.expected {
+			t.Errorf("Wrong errors for %q. Expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestParsingArrayLiteral(t *testing.T) {
+	input := "[1, 2 * 2, x,];"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.ArrayLiteral, got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("Wrong number of elements. Expected=3, got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testBinaryExpression(t, array.Elements[1], 2, "*", 2)
+	testIdentifier(t, array.Elements[2], "x")
+}
+
+func TestParsingSliceExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		low   interface{}
+		high  interface{}
+	}{
+		{"xs[1:3];", 1, 3},
+		{"xs[:3];", nil, 3},
+		{"xs[1:];", 1, nil},
+		{"xs[:];", nil, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.SliceExpression, got=%T", stmt.Expression)
+		}
+
+		testIdentifier(t, exp.Object, "xs")
+
+		for _, bound := range []struct {
+			exp      ast.Expression
+			expected interface{}
+		}{{exp.Low, tt.low}, {exp.High, tt.high}} {
+			if bound.expected == nil {
+				if bound.exp != nil {
+					t.Errorf("Slice bound of %q is not nil. got=%T", tt.input, bound.exp)
+				}
+				continue
+			}
+			testLiteralExpression(t, bound.exp, bound.expected)
+		}
+	}
+}
+
+func TestParsingArrayForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[];", "[]"},
+		{"[[1, 2], [3, [4,],],];", "[[1, 2], [3, [4]]]"},
+		{"xs[0][1];", "xs[0][1]"},
+		{"f(x)[0](y);", "f(x)[0](y)"},
+		{"[1, 2, 3][1:];", "[1, 2, 3][1:]"},
+		{"-xs[i] * 2;", "((-xs[i]) * 2)"},
+		{"xs[i] = xs[i - 1] + 1;", "(xs[i] = (xs[(i - 1)] + 1))"},
+		{"xs[xs[0]:n - 1];", "xs[xs[0]:(n - 1)]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		actual := program.Statements[0].String()
+		if actual != tt.expected {
+			t.Errorf("Wrong expression for %q. Expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestUnclosedBrackets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2;", `Expected ']' to close '[' at 1:1, got ";" at 1:6`},
+		{"xs[1;", `Expected ']' to close '[' at 1:3, got ";" at 1:5`},
+		{"xs[1:2;", `Expected ']' to close '[' at 1:3, got ";" at 1:7`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.Program()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].String() != tt.expected {
+			t.Errorf("Wrong errors for %q. Expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestParsingMapLiteral(t *testing.T) {
+	input := `let m = { "name": "x", count: 3, "nested": { a: [1,], }, };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	init := program.Statements[0].(*ast.VariableDeclaration).Declarations[0].Init
+	m, ok := init.(*ast.MapLiteral)
+	if !ok {
+		t.Fatalf("Init is not ast.MapLiteral, got=%T", init)
+	}
+
+	if len(m.Entries) != 3 {
+		t.Fatalf("Wrong number of entries. Expected=3, got=%d", len(m.Entries))
+	}
+
+	testStringLiteral(t, m.Entries[0].Key, "name")
+	testStringLiteral(t, m.Entries[0].Value, "x")
+	testIdentifier(t, m.Entries[1].Key, "count")
+	testIntegerLiteral(t, m.Entries[1].Value, 3)
+	testStringLiteral(t, m.Entries[2].Key, "nested")
+
+	nested, ok := m.Entries[2].Value.(*ast.MapLiteral)
+	if !ok {
+		t.Fatalf("Nested value is not ast.MapLiteral, got=%T", m.Entries[2].Value)
+	}
+	if nested.String() != "{a: [1]}" {
+		t.Errorf("Nested map is incorrect. Expected=%s, got=%s", "{a: [1]}", nested.String())
+	}
+}
+
+func TestMapLiteralOrBlockStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{ "a": 1 };`, "*ast.ExpressionStatement"},
+		{"{ a: 1, b: 2 };", "*ast.ExpressionStatement"},
+		{"{ a; }", "*ast.BlockStatement"},
+		{`{ "a"; }`, "*ast.BlockStatement"},
+		{"{}", "*ast.BlockStatement"},
+		{"{ { a: 1 }; }", "*ast.BlockStatement"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		actual := fmt.Sprintf("%T", program.Statements[0])
+		if actual != tt.expected {
+			t.Errorf("Wrong statement for %q. Expected=%s, got=%s", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestParsingMemberExpression(t *testing.T) {
+	input := "a.b;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MemberExpression, got=%T", stmt.Expression)
+	}
+
+	testIdentifier(t, exp.Object, "a")
+	testIdentifier(t, exp.Property, "b")
+	if exp.Computed {
+		t.Errorf("exp.Computed is true for %q", input)
+	}
+}
+
+func TestParsingComputedMemberExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		property string
+	}{
+		{`m["b"];`, `"b"`},
+		{"m[k];", "k"},
+		{`m["x${i}"];`, `"x${i}"`},
+		{"m[1 + 1];", "(1 + 1)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.MemberExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.MemberExpression for %q, got=%T", tt.input, stmt.Expression)
+		}
+
+		if !exp.Computed {
+			t.Errorf("exp.Computed is false for %q", tt.input)
+		}
+		testIdentifier(t, exp.Object, "m")
+		if exp.Property.String() != tt.property {
+			t.Errorf("Wrong property for %q. Expected=%q, got=%q", tt.input, tt.property, exp.Property.String())
+		}
+		if exp.End().Offset != len(tt.input)-1 {
+			t.Errorf("exp.End() wrong for %q. Expected offset %d, got=%d", tt.input, len(tt.input)-1, exp.End().Offset)
+		}
+	}
+}
+
+func TestParsingMemberForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a.b.c;", "a.b.c"},
+		{"obj.method(x);", "obj.method(x)"},
+		{"make().items[0].name;", "make().items[0].name"},
+		{`a["b"].c;`, `a["b"].c`},
+		{"-a.b * 2;", "((-a.b) * 2)"},
+		{"a.b = a.b + 1;", "(a.b = (a.b + 1))"},
+		{`m["key"] = { x: 1 };`, `(m["key"] = {x: 1})`},
+		{"f({ a: 1 }, {});", "f({a: 1}, {})"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		actual := program.Statements[0].String()
+		if actual != tt.expected {
+			t.Errorf("Wrong expression for %q. Expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestParsingBinaryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		left     interface{}
+		operator string
+		right    interface{}
+	}{
+		{"2 + 2;", 2, "+", 2},
+		{"2 - 2;", 2, "-", 2},
+		{"2 * 2;", 2, "*", 2},
+		{"2 / 2;", 2, "/", 2},
+		{"2 % 2;", 2, "%", 2},
+		{"2 == 2;", 2, "==", 2},
+		{"2 != 2;", 2, "!=", 2},
+		{"2 < 2;", 2, "<", 2},
+		{"2 <= 2;", 2, "<=", 2},
+		{"2 > 2;", 2, ">", 2},
+		{"2 >= 2;", 2, ">=", 2},
+		{"true == false;", true, "==", false},
+		{"nil != 2;", nil, "!=", 2},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		if !testBinaryExpression(t, stmt.Expression, tt.left, tt.operator, tt.right) {
+			return
+		}
+	}
+}
+
+func TestParsingLogicalExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		left     interface{}
+		operator string
+		right    interface{}
+	}{
+		{"1 && 2;", 1, "&&", 2},
+		{"1 || 2;", 1, "||", 2},
+		{"true && false;", true, "&&", false},
+		{"nil || true;", nil, "||", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		le, ok := stmt.Expression.(*ast.LogicalExpression)
+		if !ok {
+			t.Fatalf("Expression is not *ast.LogicalExpression. got=%T", stmt.Expression)
+		}
+		testLiteralExpression(t, le.Left, tt.left)
+		if le.Operator != tt.operator {
+			t.Fatalf("Operator is not %q. got=%q", tt.operator, le.Operator)
+		}
+		testLiteralExpression(t, le.Right, tt.right)
+	}
+}
+
+func TestParsingUnaryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		argument interface{}
+	}{
+		{"-5;", "-", 5},
+		{"+5;", "+", 5},
+		{"!5;", "!", 5},
+		{"-2.5;", "-", 2.5},
+		{`!"hello";`, "!", "hello"},
+		{"!true;", "!", true},
+		{"!nil;", "!", nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		ue, ok := stmt.Expression.(*ast.UnaryExpression)
+		if !ok {
+			t.Fatalf("Expression is not *ast.UnaryExpression. got=%T", stmt.Expression)
+		}
+		if ue.Operator != tt.operator {
+			t.Fatalf("Operator is not %q. got=%q", tt.operator, ue.Operator)
+		}
+		testLiteralExpression(t, ue.Argument, tt.argument)
+	}
+}
+
+func TestParsingIntegerLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5;", 5},
+		{"0xFF;", 255},
+		{"0o755;", 493},
+		{"0b1010;", 10},
+		{"1_000_000;", 1000000},
+		{"0755;", 755},
+		{"9223372036854775807;", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		testLiteralExpression(t, stmt.Expression, tt.expected)
+	}
+}
+
+func TestParsingBooleanLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true;", true},
+		{"false;", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		testLiteralExpression(t, stmt.Expression, tt.expected)
+	}
+}
+
+func TestParsingNilLiteral(t *testing.T) {
+	input := `nil;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+	testLiteralExpression(t, stmt.Expression, nil)
+}
+
+func TestParsingFloatLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{".5;", 0.5},
+		{"5.;", 5},
+		{"6.02e23;", 6.02e23},
+		{"1E-9;", 1e-9},
+		{"1_000.000_1;", 1000.0001},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		testLiteralExpression(t, stmt.Expression, tt.expected)
+	}
+}
+
+func TestParsingOutOfRangeNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808;", `Integer literal "9223372036854775808" is out of range at 1:1`},
+		{"0xFFFFFFFFFFFFFFFFF;", `Integer literal "0xFFFFFFFFFFFFFFFFF" is out of range at 1:1`},
+		{"1e400;", `Float literal "1e400" is out of range at 1:1`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.Program()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].String() != tt.expected {
+			t.Errorf("Wrong errors for %q. Expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestParsingStringLiteral(t *testing.T) {
+	input := `
+		"hello world";
+		'hello world';
+		"say \"hello\"\tworld";
+		` + "`raw \\t`;" + `
+	`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	testLiteralExpression(t, stmt.Expression, "hello world")
+	stmt = program.Statements[1].(*ast.ExpressionStatement)
+	testLiteralExpression(t, stmt.Expression, "hello world")
+	stmt = program.Statements[2].(*ast.ExpressionStatement)
+	testLiteralExpression(t, stmt.Expression, "say \"hello\"\tworld")
+	stmt = program.Statements[3].(*ast.ExpressionStatement)
+	testLiteralExpression(t, stmt.Expression, `raw \t`)
+}
+
+func TestParsingInterpolatedString(t *testing.T) {
+	input := `"hello ${'world'}, you have ${3 + 1} items";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	is, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("Expression is not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(is.Parts) != 5 {
+		t.Fatalf("Wrong number of parts. Expected=%d, got=%d", 5, len(is.Parts))
+	}
+	testLiteralExpression(t, is.Parts[0], "hello ")
+	testLiteralExpression(t, is.Parts[2], ", you have ")
+	testLiteralExpression(t, is.Parts[1], "world")
+	testBinaryExpression(t, is.Parts[3], 3, "+", 1)
+	testLiteralExpression(t, is.Parts[4], " items")
+
+	expected := `"hello ${'world'}, you have ${(3 + 1)} items"`
+	if is.String() != expected {
+		t.Errorf("String() is incorrect. Expected=%s, got=%s", expected, is.String())
+	}
+}
+
+func TestInterpolatedStringSource(t *testing.T) {
+	tests := []string{
+		`"cost \${price} for ${n}"`,
+		`"say \"hi\" ${x}"`,
+		`'it\'s ${a}'`,
+		"\"\"\"line\n${a} \"quoted\" \\t\"\"\"",
+		`"${a}${b}"`,
+		`"${a} and ${'b'} \u{41}"`,
+		`"\q${a}"`,
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input + ";")
+		p := New(l)
+		program := p.Program()
+
+		actual := program.Statements[0].String()
+		if actual != input {
+			t.Errorf("String() is incorrect. Expected=%s, got=%s", input, actual)
+		}
+	}
+}
+
+func TestUnterminatedPlaceholder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"${a b}";`, `"${a}"`},
+		{`'x ${a}${b c}';`, `'x ${a}${b}'`},
+		{`"${a`, `"${a}"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+
+		if len(p.Errors()) != 1 {
+			t.Errorf("Wrong number of errors for %q. Expected=1, got=%q", tt.input, p.Errors())
+		}
+
+		exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("Expression is not ast.InterpolatedString for %q, got=%T", tt.input, program.Statements[0])
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("String() is incorrect for %q. Expected=%s, got=%s", tt.input, tt.expected, exp.String())
+		}
+		if !exp.End().IsValid() {
+			t.Errorf("End() is not valid for %q", tt.input)
+		}
+	}
+}
+
+func TestOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"2 + 2 * 2;",
+			"(2 + (2 * 2))",
+		},
+		{
+			"2 * 2 + 2;",
+			"((2 * 2) + 2)",
+		},
+		{
+			"(2 + 2) * 2;",
+			"((2 + 2) * 2)",
+		},
+		{
+			"2 - 2 / 2;",
+			"(2 - (2 / 2))",
+		},
+		{
+			"2 / 2 - 2;",
+			"((2 / 2) - 2)",
+		},
+		{
+			"(2 - 2) / 2;",
+			"((2 - 2) / 2)",
+		},
+		{
+			"1 - 2 + 3;",
+			"((1 - 2) + 3)",
+		},
+		{
+			"1 + 2 - 3 + 4 - 5;",
+			"((((1 + 2) - 3) + 4) - 5)",
+		},
+		{
+			"1 % 2 / 3 * 4;",
+			"(((1 % 2) / 3) * 4)",
+		},
+		{
+			"1 + 2 * 3 - 4 / 5 % 6 + 7;",
+			"(((1 + (2 * 3)) - ((4 / 5) % 6)) + 7)",
+		},
+		{
+			"1 * 2 + 3 * 4 - 5 % 6;",
+			"(((1 * 2) + (3 * 4)) - (5 % 6))",
+		},
+		{
+			"(1 - 2) * (3 + 4) / 5 - (6 % (7 - 8));",
+			"((((1 - 2) * (3 + 4)) / 5) - (6 % (7 - 8)))",
+		},
+		{
+			"-2 * 3;",
+			"((-2) * 3)",
+		},
+		{
+			"-(2 + 3);",
+			"(-(2 + 3))",
+		},
+		{
+			"2 - -2;",
+			"(2 - (-2))",
+		},
+		{
+			"--2;",
+			"(-(-2))",
+		},
+		{
+			"!-2 + +3 * -4;",
+			"((!(-2)) + ((+3) * (-4)))",
+		},
+		{
+			"1 + 2 < 3 * 4 == 5 > 6;",
+			"(((1 + 2) < (3 * 4)) == (5 > 6))",
+		},
+		{
+			"1 || 2 && 3;",
+			"(1 || (2 && 3))",
+		},
+		{
+			"1 && 2 || 3 && 4;",
+			"((1 && 2) || (3 && 4))",
+		},
+		{
+			"!1 == 2 && 3 != 4 || 5 <= 6 - 1;",
+			"((((!1) == 2) && (3 != 4)) || (5 <= (6 - 1)))",
+		},
+		{
+			"(1 || 2) && 3;",
+			"((1 || 2) && 3)",
+		},
+		{
+			"a = b = c;",
+			"(a = (b = c))",
+		},
+		{
+			"x += y -= 1 + 2 * 3;",
+			"(x += (y -= (1 + (2 * 3))))",
+		},
+		{
+			"a = b || c && d == e;",
+			"(a = (b || (c && (d == e))))",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		actual := p.Program().String()
+		checkParserErrors(t, p)
+
+		fmt.Println(actual)
+
+		if actual != tt.expected {
+			t.Errorf("Program is incorrect. Expected=%v+, got=%v+", tt.expected, actual)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   []string
+		statements int
+	}{
+		{
+			"let = 5;\nlet x = ;\nx + ;\nlet y = 1;",
+			[]string{
+				`Unexpected token "=", expected "IDENT" at 1:5`,
+				`Unexpected literal ";" at 2:9`,
+				`Unexpected literal ";" at 3:5`,
+			},
+			4,
+		},
+		{
+			"let x = (1 + 2;\nlet y = f(1, 2;\nz;",
+			[]string{
+				`Expected ')' to close '(' at 1:9, got ";" at 1:15`,
+				`Expected ')' to close '(' at 2:10, got ";" at 2:15`,
+			},
+			3,
+		},
+		{
+			"x = 1 y = 2;\nz;",
+			[]string{`Unexpected token "IDENT", expected ";" at 1:7`},
+			2,
+		},
+		{
+			"} x; ] ] ; y;",
+			[]string{
+				`Unexpected token "}" at 1:1`,
+				`Unexpected token "]" at 1:6`,
+			},
+			4,
+		},
+		{
+			"def f(a { return a; }\nlet x = 1;",
+			[]string{`Expected ')' to close '(' at 1:6, got "{" at 1:9`},
+			2,
+		},
+		{
+			"if (a { b; } c;",
+			[]string{`Expected ')' to close '(' at 1:4, got "{" at 1:7`},
+			2,
+		},
+		{
+			"while (a) { let = 1; b; * ; }\nc;",
+			[]string{
+				`Unexpected token "=", expected "IDENT" at 1:17`,
+				`Unexpected token "*" at 1:25`,
+			},
+			2,
+		},
+		{
+			"let m = { 1: 2, a: };\nm;",
+			[]string{`Invalid map key "1" at 1:11`},
+			2,
+		},
+		{
+			"if (a) ; b;",
+			[]string{`Unexpected token ";", expected "{" at 1:8`},
+			2,
+		},
+		{
+			"def f(a) ; let x = 1;",
+			[]string{`Unexpected token ";", expected "{" at 1:10`},
+			2,
+		},
+		{
+			"while (a) ] { b; }",
+			[]string{`Unexpected token "]", expected "{" at 1:11`},
+			2,
+		},
+		{
+			"f(1, 2\nlet x = 1;",
+			[]string{`Expected ')' to close '(' at 1:2, got "LET" at 2:1`},
+			2,
+		},
+		{
+			"x = while;\ny;",
+			[]string{`Unexpected literal "WHILE" at 1:5`},
+			2,
+		},
+		{
+			"let x = return 1;\ny;",
+			[]string{`Unexpected literal "RETURN" at 1:9`},
+			2,
+		},
+		{
+			"let x =\nlet y = 1;\nz;",
+			[]string{`Unexpected literal "LET" at 2:1`},
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Program()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("Wrong errors for %q. Expected=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i].String() != msg {
+				t.Errorf("Wrong error %d for %q. Expected=%q, got=%q", i, tt.input, msg, errors[i])
+			}
+		}
+
+		if len(program.Statements) != tt.statements {
+			t.Errorf("Wrong number of statements for %q. Expected=%d, got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+
+		// Broken programs must still print without panicking.
+		_ = program.String()
+	}
+}
+
+func TestBadNodes(t *testing.T) {
+	input := "let x = ;\n] y;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("Wrong number of statements. Expected=3, got=%d", len(program.Statements))
+	}
+
+	decl := program.Statements[0].(*ast.VariableDeclaration)
+	if _, ok := decl.Declarations[0].Init.(*ast.BadExpression); !ok {
+		t.Errorf("Init is not ast.BadExpression, got=%T", decl.Declarations[0].Init)
+	}
+
+	bad, ok := program.Statements[1].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("program.Body[1] is not ast.BadStatement, got=%T", program.Statements[1])
+	}
+	if bad.Pos().String() != "2:1" || bad.End().String() != "2:3" {
+		t.Errorf("Wrong BadStatement span. Expected=2:1-2:3, got=%s-%s", bad.Pos(), bad.End())
+	}
+
+	if _, ok := program.Statements[2].(*ast.ExpressionStatement); !ok {
+		t.Errorf("program.Body[2] is not ast.ExpressionStatement, got=%T", program.Statements[2])
+	}
+}
+
+func TestBadForInVariable(t *testing.T) {
+	input := "for (a.b in xs) {}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.Program()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("Wrong number of errors. Expected=1, got=%q", p.Errors())
+	}
+
+	stmt := program.Statements[0].(*ast.ForInStatement)
+	bad, ok := stmt.Left.(*ast.BadExpression)
+	if !ok {
+		t.Fatalf("stmt.Left is not ast.BadExpression, got=%T", stmt.Left)
+	}
+	if bad.Pos().String() != "1:6" || bad.End().String() != "1:9" {
+		t.Errorf("Wrong BadExpression span. Expected=1:6-1:9, got=%s-%s", bad.Pos(), bad.End())
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	input := "@ let x = f(1, 2;\n5 = y;"
+
+	l := lexer.NewFile("test.gero", input)
+	p := New(l)
+	p.Program()
+
+	diagnostics := p.Diagnostics().Diagnostics()
+	if len(diagnostics) != 3 {
+		t.Fatalf("Wrong number of diagnostics. Expected=3, got=%d (%q)", len(diagnostics), diagnostics)
+	}
+
+	tests := []struct {
+		code    diag.Code
+		message string
+		start   string
+		end     string
+		labels  int
+		notes   int
+		fixes   int
+	}{
+		{diag.UnexpectedCharacter, "Unexpected character '@'", "test.gero:1:1", "test.gero:1:2", 0, 0, 0},
+		{diag.UnclosedDelimiter, `Expected ')' to close '(' at test.gero:1:12, got ";"`, "test.gero:1:17", "test.gero:1:18", 1, 0, 1},
+		{diag.InvalidAssignmentTarget, `Invalid assignment target "5"`, "test.gero:2:1", "test.gero:2:2", 0, 1, 0},
+	}
+
+	for i, tt := range tests {
+		d := diagnostics[i]
+		if d.Severity != diag.Error {
+			t.Errorf("Tests[%d] - Wrong severity. Expected=%s, got=%s", i, diag.Error, d.Severity)
+		}
+		if d.Code != tt.code {
+			t.Errorf("Tests[%d] - Wrong code. Expected=%s, got=%s", i, tt.code, d.Code)
+		}
+		if d.Message != tt.message {
+			t.Errorf("Tests[%d] - Wrong message. Expected=%q, got=%q", i, tt.message, d.Message)
+		}
+		if d.Span.Start.String() != tt.start || d.Span.End.String() != tt.end {
+			t.Errorf("Tests[%d] - Wrong span. Expected=%s-%s, got=%s-%s", i, tt.start, tt.end, d.Span.Start, d.Span.End)
+		}
+		if len(d.Labels) != tt.labels || len(d.Notes) != tt.notes || len(d.Fixes) != tt.fixes {
+			t.Errorf("Tests[%d] - Wrong labels, notes or fixes. got=%+v", i, d)
+		}
+	}
+
+	label := diagnostics[1].Labels[0]
+	if label.Span.Start.String() != "test.gero:1:12" {
+		t.Errorf("Wrong label position. Expected=test.gero:1:12, got=%s", label.Span.Start)
+	}
+
+	fix := diagnostics[1].Fixes[0]
+	if fix.Replacement != ")" || fix.Span.Start != fix.Span.End || fix.Span.Start.String() != "test.gero:1:17" {
+		t.Errorf("Wrong fix. got=%+v", fix)
+	}
+
+	if !p.Diagnostics().HasErrors() || len(p.Errors()) != 3 {
+		t.Errorf("Collector does not report the errors. got=%q", p.Errors())
+	}
+}
+
+func TestIllegalTokensAreReportedOnce(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 @ + 2;", "Unexpected character '@' at 1:3"},
+		{"let x = 12abc;", `Invalid number literal "12abc" at 1:9`},
+		{"f(0x, 2);", `Missing digits after "0x" at 1:3`},
+		{"let y = 1 # 2;", "Unexpected character '#' at 1:11"},
+		{"@ x;", "Unexpected character '@' at 1:1"},
+		{`"a ${x`, "Unterminated string at 1:1"},
+		{`let s = "a ${x`, "Unterminated string at 1:9"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.Program()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].String() != tt.expected {
+			t.Errorf("Wrong errors for %q. Expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestIllegalOperandIsBadExpression(t *testing.T) {
+	l := lexer.New("f(0x, 2);")
+	p := New(l)
+	program := p.Program()
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if len(call.Arguments) != 2 {
+		t.Fatalf("Wrong number of arguments. Expected=2, got=%d", len(call.Arguments))
+	}
+
+	bad, ok := call.Arguments[0].(*ast.BadExpression)
+	if !ok {
+		t.Fatalf("call.Arguments[0] is not ast.BadExpression, got=%T", call.Arguments[0])
+	}
+	if bad.Pos().String() != "1:3" || bad.End().String() != "1:5" {
+		t.Errorf("Wrong BadExpression span. Expected=1:3-1:5, got=%s-%s", bad.Pos(), bad.End())
+	}
+	testIntegerLiteral(t, call.Arguments[1], 2)
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "{\n  1 + 22;\n}"
+
+	l := lexer.NewFile("test.gero", input)
+	p := New(l)
+	program := p.Program()
+	checkParserErrors(t, p)
+
+	block := program.Statements[0].(*ast.BlockStatement)
+	stmt := block.Body[0].(*ast.ExpressionStatement)
+	exp := stmt.Expression.(*ast.BinaryExpression)
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program, "test.gero:1:1", "test.gero:3:2"},
+		{block, "test.gero:1:1", "test.gero:3:2"},
+		{stmt, "test.gero:2:3", "test.gero:2:10"},
+		{exp, "test.gero:2:3", "test.gero:2:9"},
+		{exp.Right, "test.gero:2:7", "test.gero:2:9"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedStart {
+			t.Errorf("Tests[%d] - Wrong start position. Expected=%s, got=%s", i, tt.expectedStart, tt.node.Pos())
+		}
+		if tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("Tests[%d] - Wrong end position. Expected=%s, got=%s", i, tt.expectedEnd, tt.node.End())
+		}
+	}
+}
+
+func TestOperatorPrecedenceInterleavings(t *testing.T) {
+	precedences := map[string]int{
+		"||": 1,
+		"&&": 2,
+		"==": 3, "!=": 3,
+		"<": 4, "<=": 4, ">": 4, ">=": 4,
+		"+": 5, "-": 5,
+		"*": 6, "/": 6, "%": 6,
+	}
+	var operators []string
+	for op := range precedences {
+		operators = append(operators, op)
+	}
+	sort.Strings(operators)
+
+	for _, a := range operators {
+		for _, b := range operators {
+			for _, c := range operators {
+				ops := []string{a, b, c}
+				input := fmt.Sprintf("1 %s 2 %s 3 %s 4;", a, b, c)
+				expected := parenthesize([]string{"1", "2", "3", "4"}, ops, precedences)
+
+				l := lexer.New(input)
+				p := New(l)
+				actual := p.Program().String()
+				checkParserErrors(t, p)
+
+				if actual != expected {
+					t.Errorf("Program %q is incorrect. Expected=%s, got=%s", input, expected, actual)
+				}
+			}
+		}
+	}
+}
+
+// parenthesize is a reference shunting-yard evaluation of left-associative
+// operators, used to check the parser on every operator interleaving.
+func parenthesize(operands []string, ops []string, precedences map[string]int) string {
+	out := []string{operands[0]}
+	var stack []string
+
+	reduce := func() {
+		op := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		right, left := out[len(out)-1], out[len(out)-2]
+		out = append(out[:len(out)-2], "("+left+" "+op+" "+right+")")
+	}
+
+	for i, op := range ops {
+		for len(stack) > 0 && precedences[stack[len(stack)-1]] >= precedences[op] {
+			reduce()
+		}
+		stack = append(stack, op)
+		out = append(out, operands[i+1])
+	}
+	for len(stack) > 0 {
+		reduce()
+	}
+
+	return out[0]
+}
+
+func checkParserErrors(t *testing.T, p *Parser) {
+	errors := p.Errors()
+	if len(errors) == 0 {
+		return
+	}
+
+	t.Errorf("Parser has %d errors", len(errors))
+	for _, msg := range errors {
+		t.Errorf("Parser error: %q", msg)
+	}
+	t.FailNow()
+}
+
+func testBinaryExpression(
+	t *testing.T,
+	exp ast.Expression,
+	left interface{},
+	operator string,
+	right interface{},
+) bool {
+	be, ok := exp.(*ast.BinaryExpression)
+	if !ok {
+		t.Errorf("Expression is not *ast.BinaryExpression. got=%T", be)
+		return false
+	}
+	if !testLiteralExpression(t, be.Left, left) {
+		return false
+	}
+	if be.Operator != operator {
+		return false
+	}
+	if !testLiteralExpression(t, be.Right, right) {
+		return false
+	}
+
+	return true
+}
+
+func testLiteralExpression(
+	t *testing.T,
+	exp ast.Expression,
+	expected interface{},
+) bool {
+	switch v := expected.(type) {
+	case int:
+		return testIntegerLiteral(t, exp, int64(v))
+	case int64:
+		return testIntegerLiteral(t, exp, v)
+	case float64:
+		return testFloatLiteral(t, exp, v)
+	case bool:
+		return testBooleanLiteral(t, exp, v)
+	case nil:
+		return testNilLiteral(t, exp)
+	case string:
+		return testStringLiteral(t, exp, v)
+	}
+	t.Errorf("type of exp not handled. got=%T", exp)
+	return false
+}
+
+func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		t.Errorf("Expression is not *ast.Identifier. got=%T", exp)
+		return false
+	}
+
+	if ident.Name != value {
+		t.Errorf("Identifier.Name not %s. got=%s", value, ident.Name)
+		return false
+	}
+
+	return true
+}
+
+func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
+	lit, ok := il.(*ast.IntegerLiteral)
+	if !ok {
+		t.Errorf("Literal is not *ast.IntegerLiteral. got=%T", il)
+		return false
+	}
+
+	if lit.Value != value {
+		t.Errorf("Literal.Value not %d. got=%d", value, lit.Value)
+		return false
+	}
+
+	return true
+}
+
+func testFloatLiteral(t *testing.T, fl ast.Expression, value float64) bool {
+	lit, ok := fl.(*ast.FloatLiteral)
+	if !ok {
+		t.Errorf("Literal is not *ast.FloatLiteral. got=%T", fl)
+		return false
+	}
+
+	if lit.Value != value {
+		t.Errorf("Literal.Value not %g. got=%g", value, lit.Value)
+		return false
+	}
+
+	return true
+}
+
+func testBooleanLiteral(t *testing.T, bl ast.Expression, value bool) bool {
+	lit, ok := bl.(*ast.BooleanLiteral)
+	if !ok {
+		t.Errorf("Literal is not *ast.BooleanLiteral. got=%T", bl)
+		return false
+	}
+
+	if lit.Value != value {
+		t.Errorf("Literal.Value not %t. got=%t", value, lit.Value)
+		return false
+	}
+
+	return true
+}
+
+func testNilLiteral(t *testing.T, nl ast.Expression) bool {
+	if _, ok := nl.(*ast.NilLiteral); !ok {
+		t.Errorf("Literal is not *ast.NilLiteral. got=%T", nl)
 		return false
 	}
 
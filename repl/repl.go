@@ -37,7 +37,7 @@ func Start(in io.Reader, out io.Writer) {
 		program := p.Program()
 
 		if len(p.Errors()) != 0 {
-			util.PrintParserErrors(out, p.Errors())
+			util.PrintDiagnostics(out, p.Errors())
 		}
 
 		json, err := json.MarshalIndent(program, "", "    ")
@@ -0,0 +1,43 @@
+package token
+
+import "fmt"
+
+// Pos is a location in a source file. Line and Column are 1-based, Offset is
+// the 0-based byte offset from the start of the file.
+type Pos struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// IsValid reports whether the position has been set by the lexer.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String formats the position as "file:line:column", leaving out the parts
+// that are not known.
+func (p Pos) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// Span is the half-open source range [Start, End) covered by a token or node.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%s-%d:%d", s.Start, s.End.Line, s.End.Column)
+}
@@ -5,19 +5,38 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
-	Line    int
+	Span    Span
+
+	// Trivia around the token, only recorded when the lexer is asked to. The
+	// trailing trivia runs up to and including the end of the token's line.
+	LeadingTrivia  []Trivia `json:",omitempty"`
+	TrailingTrivia []Trivia `json:",omitempty"`
+}
+
+// Trivia is source text with no meaning to the parser: a WHITESPACE, NEWLINE
+// or COMMENT.
+type Trivia struct {
+	Type    TokenType
+	Literal string
+	Span    Span
 }
 
 var keywords = map[string]TokenType{
-	"def":    FUNCTION,
-	"module": MODULE,
-	"import": IMPORT,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"def":      FUNCTION,
+	"module":   MODULE,
+	"import":   IMPORT,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"nil":      NIL,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -40,6 +59,12 @@ const (
 	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
+	// Interpolated strings are split around their placeholders:
+	// "a ${x} b ${y} c" is STRING_HEAD, x, STRING_MIDDLE, y, STRING_TAIL.
+	STRING_HEAD   = "STRING_HEAD"
+	STRING_MIDDLE = "STRING_MIDDLE"
+	STRING_TAIL   = "STRING_TAIL"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -51,13 +76,28 @@ const (
 	EQ       = "=="
 	NOT_EQ   = "!="
 
-	LT = "<"
-	GT = ">"
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
+
+	AND = "&&"
+	OR  = "||"
+
+	ARROW   = "->"
+	DOT_DOT = ".."
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PERCENT_ASSIGN  = "%="
 
 	// Delimiters
 	COMMA = ","
 	SEMI  = ";"
 	COLON = ":"
+	DOT   = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -73,7 +113,13 @@ const (
 	LET      = "LET"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NIL      = "NIL"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
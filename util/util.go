@@ -1,14 +1,32 @@
 package util
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/TwiN/go-color"
+	"github.com/jellycat-io/gero/diag"
 )
 
-func PrintParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, color.InBold(color.InRed("parser errors:\n")))
-	for _, msg := range errors {
-		io.WriteString(out, color.InRed("\t"+msg+"\n"))
+// PrintDiagnostics writes the diagnostics to out, each followed by its
+// labels, notes and suggested fixes.
+func PrintDiagnostics(out io.Writer, diagnostics []diag.Diagnostic) {
+	for _, d := range diagnostics {
+		paint := color.InRed
+		if d.Severity != diag.Error {
+			paint = color.InYellow
+		}
+
+		io.WriteString(out, color.InBold(paint(fmt.Sprintf("%s[%s]: ", d.Severity, d.Code))))
+		io.WriteString(out, paint(d.String())+"\n")
+		for _, label := range d.Labels {
+			io.WriteString(out, fmt.Sprintf("\t%s: %s\n", label.Span.Start, label.Message))
+		}
+		for _, note := range d.Notes {
+			io.WriteString(out, fmt.Sprintf("\tnote: %s\n", note))
+		}
+		for _, fix := range d.Fixes {
+			io.WriteString(out, fmt.Sprintf("\thelp: %s at %s\n", fix.Message, fix.Span.Start))
+		}
 	}
 }